@@ -7,6 +7,7 @@ import (
 	"github.com/wpgitupdater/wpgitupdater/internal/constants"
 	"github.com/wpgitupdater/wpgitupdater/internal/git"
 	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
 	"github.com/wpgitupdater/wpgitupdater/internal/utils"
 	"io/ioutil"
 	"net/http"
@@ -16,8 +17,12 @@ func UpdateUsage(usageType string, slug string, stats bool) error {
 	var provider string
 	var repository string
 	if stats {
-		provider = git.GetProvider()
-		repository = git.GetRepository()
+		remote, err := git.GetRemote()
+		if err != nil {
+			return err
+		}
+		provider = remote.Host
+		repository = remote.Path
 	} else {
 		slug = ""
 		provider = "*"
@@ -29,7 +34,11 @@ func UpdateUsage(usageType string, slug string, stats bool) error {
 	if err != nil {
 		return err
 	}
-	url := constants.ApiUrl + "/" + utils.GetUpdaterToken() + "/usage"
+	token, err := utils.GetUpdaterToken()
+	if err != nil {
+		return err
+	}
+	url := constants.ApiUrl + "/" + token + "/usage"
 	client := &http.Client{}
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
@@ -41,10 +50,10 @@ func UpdateUsage(usageType string, slug string, stats bool) error {
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := client.Do(req)
-	defer resp.Body.Close()
 	if err != nil {
-		return err
+		return logger.Error(err)
 	}
+	defer resp.Body.Close()
 
 	responseBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -52,7 +61,7 @@ func UpdateUsage(usageType string, slug string, stats bool) error {
 	}
 
 	if resp.StatusCode != 201 {
-		return errors.New(string(responseBody))
+		return logger.Error(errors.New(string(responseBody)))
 	}
 
 	return nil
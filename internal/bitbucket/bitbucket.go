@@ -0,0 +1,265 @@
+package bitbucket
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/constants"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Cloud opens pull requests on a workspace/repository of bitbucket.org
+// through the 2.0 API.
+type Cloud struct {
+	unsupported
+	Config     *config.Config
+	ApiUrl     string
+	Repository string
+}
+
+func NewCloudProvider(cnf *config.Config, repository string) Cloud {
+	apiUrl := strings.TrimRight(cnf.Provider.Url, "/")
+	if apiUrl == "" {
+		apiUrl = "https://api.bitbucket.org/2.0"
+	}
+	return Cloud{Config: cnf, ApiUrl: apiUrl, Repository: repository}
+}
+
+// cloudPull is a pull request as returned by the 2.0 API.
+type cloudPull struct {
+	Id          int    `json:"id"`
+	State       string `json:"state"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Source      struct {
+		Branch struct {
+			Name string `json:"name"`
+		} `json:"branch"`
+	} `json:"source"`
+	Destination struct {
+		Branch struct {
+			Name string `json:"name"`
+		} `json:"branch"`
+	} `json:"destination"`
+	Links struct {
+		Html struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+func (p cloudPull) toPullRequest() interfaces.PullRequest {
+	return interfaces.PullRequest{Number: p.Id, Url: p.Links.Html.Href, State: getState(p.State), Title: p.Title, Body: p.Description, Head: p.Source.Branch.Name, Base: p.Destination.Branch.Name}
+}
+
+func (cloud Cloud) CreatePullRequest(pr interfaces.PullRequest) (interfaces.PullRequest, error) {
+	body := map[string]interface{}{
+		"title":               pr.Title,
+		"description":         pr.Body,
+		"source":              map[string]interface{}{"branch": map[string]string{"name": pr.Head}},
+		"destination":         map[string]interface{}{"branch": map[string]string{"name": pr.Base}},
+		"close_source_branch": true,
+		"draft":               pr.Draft,
+	}
+
+	responseBody, err := request(cloud.Config, "POST", cloud.getUrl("/pullrequests"), body)
+	if err != nil {
+		return pr, err
+	}
+
+	created := cloudPull{}
+	err = json.Unmarshal(responseBody, &created)
+	return created.toPullRequest(), err
+}
+
+func (cloud Cloud) FindPullRequests(prefix string) ([]interfaces.PullRequest, error) {
+	// ~ matches anywhere in the name
+	query := url.QueryEscape(`source.branch.name ~ "` + prefix + `"`)
+	return cloud.listPullRequests("state=OPEN&q="+query, func(pr interfaces.PullRequest) bool {
+		return strings.HasPrefix(pr.Head, prefix)
+	})
+}
+
+func (cloud Cloud) GetPullRequests(head string) ([]interfaces.PullRequest, error) {
+	query := url.QueryEscape(`source.branch.name = "` + head + `"`)
+	return cloud.listPullRequests("state=OPEN&state=MERGED&state=DECLINED&state=SUPERSEDED&q="+query, func(pr interfaces.PullRequest) bool {
+		return pr.Head == head
+	})
+}
+
+func (cloud Cloud) listPullRequests(query string, filter func(pr interfaces.PullRequest) bool) ([]interfaces.PullRequest, error) {
+	found := []interfaces.PullRequest{}
+	next := cloud.getUrl("/pullrequests?pagelen=50&" + query)
+	for next != "" {
+		responseBody, err := request(cloud.Config, "GET", next, nil)
+		if err != nil {
+			return found, err
+		}
+		page := struct {
+			Values []cloudPull `json:"values"`
+			Next   string      `json:"next"`
+		}{}
+		if err := json.Unmarshal(responseBody, &page); err != nil {
+			return found, err
+		}
+		for _, p := range page.Values {
+			if pr := p.toPullRequest(); filter(pr) {
+				found = append(found, pr)
+			}
+		}
+		next = page.Next
+	}
+	return found, nil
+}
+
+func (cloud Cloud) UpdatePullRequest(pr interfaces.PullRequest) error {
+	_, err := request(cloud.Config, "PUT", cloud.getUrl("/pullrequests/"+strconv.Itoa(pr.Number)), map[string]string{"title": pr.Title, "description": pr.Body})
+	return err
+}
+
+func (cloud Cloud) ClosePullRequest(pr interfaces.PullRequest, comment string) error {
+	url := cloud.getUrl("/pullrequests/" + strconv.Itoa(pr.Number))
+	content := map[string]interface{}{"content": map[string]string{"raw": comment}}
+	return decline(cloud.Config, url+"/comments", content, func() (string, error) {
+		return url + "/decline", nil
+	})
+}
+
+// RequestReviewers accepts reviewers by account id or {uuid}, Bitbucket Cloud
+// has no team reviewers.
+func (cloud Cloud) RequestReviewers(pr interfaces.PullRequest, reviewers []string, teamReviewers []string) error {
+	cloud.skipTeamReviewers(teamReviewers)
+	if len(reviewers) == 0 {
+		return nil
+	}
+	users := []map[string]string{}
+	for _, reviewer := range reviewers {
+		if strings.HasPrefix(reviewer, "{") {
+			users = append(users, map[string]string{"uuid": reviewer})
+		} else {
+			users = append(users, map[string]string{"account_id": reviewer})
+		}
+	}
+	body := map[string]interface{}{"title": pr.Title, "description": pr.Body, "reviewers": users}
+	_, err := request(cloud.Config, "PUT", cloud.getUrl("/pullrequests/"+strconv.Itoa(pr.Number)), body)
+	return err
+}
+
+func (cloud Cloud) getUrl(path string) string {
+	return cloud.ApiUrl + "/repositories/" + cloud.Repository + path
+}
+
+// unsupported is embedded by both providers for the pull request features
+// Bitbucket lacks.
+type unsupported struct{}
+
+// AddLabels does nothing, Bitbucket pull requests have no labels.
+func (unsupported) AddLabels(pr interfaces.PullRequest, labels []string) error {
+	logger.Printf("Bitbucket does not support labels, skipping [%s]\n", strings.Join(labels, ", "))
+	return nil
+}
+
+// AddAssignees does nothing, Bitbucket pull requests have no assignees.
+func (unsupported) AddAssignees(pr interfaces.PullRequest, assignees []string) error {
+	logger.Printf("Bitbucket does not support assignees, skipping [%s]\n", strings.Join(assignees, ", "))
+	return nil
+}
+
+// SetMilestone does nothing, Bitbucket pull requests have no milestones.
+func (unsupported) SetMilestone(pr interfaces.PullRequest, milestone string) error {
+	logger.Printf("Bitbucket does not support milestones, skipping [%s]\n", milestone)
+	return nil
+}
+
+// EnableAutoMerge does nothing, the Bitbucket API cannot merge once checks pass.
+func (unsupported) EnableAutoMerge(pr interfaces.PullRequest, method string) error {
+	logger.Printf("Bitbucket does not support auto-merge, skipping [%s]\n", method)
+	return nil
+}
+
+// skipTeamReviewers reports team reviewers, which Bitbucket has none of.
+func (unsupported) skipTeamReviewers(teamReviewers []string) {
+	if len(teamReviewers) > 0 {
+		logger.Printf("Bitbucket does not support team reviewers, skipping [%s]\n", strings.Join(teamReviewers, ", "))
+	}
+}
+
+// decline closes a pull request for either provider, as Bitbucket has no
+// closed state, after posting comment to commentUrl. declineUrl is resolved
+// after commenting, as the Server API requires the current version.
+func decline(cnf *config.Config, commentUrl string, comment interface{}, declineUrl func() (string, error)) error {
+	if _, err := request(cnf, "POST", commentUrl, comment); err != nil {
+		return err
+	}
+	url, err := declineUrl()
+	if err != nil {
+		return err
+	}
+	_, err = request(cnf, "POST", url, nil)
+	return err
+}
+
+// getState maps the pull request states of both Bitbucket APIs, declined and
+// superseded pull requests being closed.
+func getState(state string) string {
+	switch state {
+	case "OPEN":
+		return interfaces.PullRequestOpen
+	case "MERGED":
+		return interfaces.PullRequestMerged
+	default:
+		return interfaces.PullRequestClosed
+	}
+}
+
+// request sends an authenticated API request. Tokens in the form
+// username:app-password use basic authentication, any other token is sent as
+// a bearer access token.
+func request(cnf *config.Config, method string, url string, payload interface{}) ([]byte, error) {
+	var data []byte
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		data = encoded
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(cnf.Token, ":") {
+		req.Header.Add("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(cnf.Token)))
+	} else {
+		req.Header.Add("Authorization", "Bearer "+cnf.Token)
+	}
+	req.Header.Add("User-Agent", constants.UserAgent)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, logger.Error(err)
+	}
+
+	defer resp.Body.Close()
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseBody, logger.Error(errors.New(strconv.Itoa(resp.StatusCode) + " " + string(responseBody)))
+	}
+
+	return responseBody, nil
+}
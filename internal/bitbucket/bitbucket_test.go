@@ -0,0 +1,112 @@
+package bitbucket
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/fakeapi"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestCloudProvider(url string) Cloud {
+	return NewCloudProvider(&config.Config{Token: "secret", Provider: config.ProviderConfig{Url: url}}, "ws/repo")
+}
+
+func TestCloudProvider(t *testing.T) {
+	pr := interfaces.PullRequest{Number: 7, Title: "Update akismet", Head: "wpgitupdates-plugin-akismet-4.0-4.1", Base: "main", Body: "Changes", Draft: true}
+	fakeapi.Run(t, []fakeapi.Case{
+		{
+			Name:      "create",
+			Responses: map[string]string{"POST /repositories/ws/repo/pullrequests": `{"id":7,"state":"OPEN","title":"Update akismet","description":"Changes","source":{"branch":{"name":"wpgitupdates-plugin-akismet-4.0-4.1"}},"destination":{"branch":{"name":"main"}},"links":{"html":{"href":"https://bitbucket.org/ws/repo/pull-requests/7"}}}`},
+			Run: func(url string) (interface{}, error) {
+				return newTestCloudProvider(url).CreatePullRequest(pr)
+			},
+			Want: interfaces.PullRequest{Number: 7, Url: "https://bitbucket.org/ws/repo/pull-requests/7", State: interfaces.PullRequestOpen, Title: "Update akismet", Body: "Changes", Head: "wpgitupdates-plugin-akismet-4.0-4.1", Base: "main"},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: "/repositories/ws/repo/pullrequests", Body: `{"close_source_branch":true,"description":"Changes","destination":{"branch":{"name":"main"}},"draft":true,"source":{"branch":{"name":"wpgitupdates-plugin-akismet-4.0-4.1"}},"title":"Update akismet"}`},
+			},
+		},
+		{
+			Name:      "find",
+			Responses: map[string]string{"GET /repositories/ws/repo/pullrequests": `{"values":[{"id":7,"state":"OPEN","source":{"branch":{"name":"wpgitupdates-plugin-akismet-4.0-4.1"}}},{"id":8,"state":"OPEN","source":{"branch":{"name":"feature-wpgitupdates-plugin-akismet-"}}}]}`},
+			Run: func(url string) (interface{}, error) {
+				return newTestCloudProvider(url).FindPullRequests("wpgitupdates-plugin-akismet-")
+			},
+			Want: []interfaces.PullRequest{{Number: 7, State: interfaces.PullRequestOpen, Head: "wpgitupdates-plugin-akismet-4.0-4.1"}},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: "/repositories/ws/repo/pullrequests?pagelen=50&state=OPEN&q=source.branch.name+~+%22wpgitupdates-plugin-akismet-%22", Body: ""},
+			},
+		},
+		{
+			Name:      "find by head",
+			Responses: map[string]string{"GET /repositories/ws/repo/pullrequests": `{"values":[{"id":7,"state":"MERGED","source":{"branch":{"name":"wpgitupdates-a"}}},{"id":8,"state":"DECLINED","source":{"branch":{"name":"wpgitupdates-a"}}}]}`},
+			Run: func(url string) (interface{}, error) {
+				return newTestCloudProvider(url).GetPullRequests("wpgitupdates-a")
+			},
+			Want: []interfaces.PullRequest{{Number: 7, State: interfaces.PullRequestMerged, Head: "wpgitupdates-a"}, {Number: 8, State: interfaces.PullRequestClosed, Head: "wpgitupdates-a"}},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: "/repositories/ws/repo/pullrequests?pagelen=50&state=OPEN&state=MERGED&state=DECLINED&state=SUPERSEDED&q=source.branch.name+%3D+%22wpgitupdates-a%22", Body: ""},
+			},
+		},
+		{
+			Name: "update",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestCloudProvider(url).UpdatePullRequest(pr)
+			},
+			Calls: []fakeapi.Call{
+				{Method: "PUT", Uri: "/repositories/ws/repo/pullrequests/7", Body: `{"description":"Changes","title":"Update akismet"}`},
+			},
+		},
+		{
+			Name: "close",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestCloudProvider(url).ClosePullRequest(pr, "Superseded by #9")
+			},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: "/repositories/ws/repo/pullrequests/7/comments", Body: `{"content":{"raw":"Superseded by #9"}}`},
+				{Method: "POST", Uri: "/repositories/ws/repo/pullrequests/7/decline", Body: ""},
+			},
+		},
+		{
+			Name: "labels",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestCloudProvider(url).AddLabels(pr, []string{"dependencies"})
+			},
+			Calls: []fakeapi.Call{},
+		},
+	}, "Authorization", "Bearer secret")
+}
+
+func TestRequestAuthorization(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"secret", "Bearer secret"},
+		{"user:app-password", "Basic dXNlcjphcHAtcGFzc3dvcmQ="},
+	}
+
+	for _, test := range tests {
+		api := fakeapi.New(nil)
+		if _, err := request(&config.Config{Token: test.token}, "GET", api.URL, nil); err != nil {
+			t.Fatal(err)
+		}
+		api.Close()
+		if got := api.Headers[0].Get("Authorization"); got != test.want {
+			t.Errorf("token [%s] sent authorization [%s], want [%s]", test.token, got, test.want)
+		}
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"Branch not found"}}`, http.StatusBadRequest)
+	}))
+	defer api.Close()
+
+	if _, err := request(&config.Config{Token: "secret"}, "GET", api.URL, nil); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("got error %v, want 400", err)
+	}
+}
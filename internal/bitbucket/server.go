@@ -0,0 +1,177 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Server opens pull requests on a repository of a self-hosted Bitbucket
+// Server or Data Center instance through the REST 1.0 API.
+type Server struct {
+	unsupported
+	Config  *config.Config
+	ApiUrl  string
+	Project string
+	Slug    string
+}
+
+// NewServerProvider accepts repository paths from both https clone urls,
+// scm/PROJECT/repo, and ssh clone urls, project/repo.
+func NewServerProvider(cnf *config.Config, baseUrl string, repository string) (Server, error) {
+	apiUrl := strings.TrimRight(cnf.Provider.Url, "/")
+	if apiUrl == "" {
+		apiUrl = baseUrl + "/rest/api/1.0"
+	}
+
+	parts := strings.Split(strings.TrimPrefix(repository, "scm/"), "/")
+	if len(parts) != 2 {
+		return Server{}, fmt.Errorf("invalid bitbucket server repository [%s], expected project/repo", repository)
+	}
+	return Server{Config: cnf, ApiUrl: apiUrl, Project: parts[0], Slug: parts[1]}, nil
+}
+
+// serverPull is a pull request as returned by the REST 1.0 API.
+type serverPull struct {
+	Id          int    `json:"id"`
+	Version     int    `json:"version"`
+	State       string `json:"state"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	FromRef     struct {
+		DisplayId string `json:"displayId"`
+	} `json:"fromRef"`
+	ToRef struct {
+		DisplayId string `json:"displayId"`
+	} `json:"toRef"`
+	Links struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+func (p serverPull) toPullRequest() interfaces.PullRequest {
+	pr := interfaces.PullRequest{Number: p.Id, State: getState(p.State), Title: p.Title, Body: p.Description, Head: p.FromRef.DisplayId, Base: p.ToRef.DisplayId}
+	if len(p.Links.Self) > 0 {
+		pr.Url = p.Links.Self[0].Href
+	}
+	return pr
+}
+
+func (server Server) CreatePullRequest(pr interfaces.PullRequest) (interfaces.PullRequest, error) {
+	body := map[string]interface{}{
+		"title":       pr.Title,
+		"description": pr.Body,
+		"fromRef":     map[string]string{"id": "refs/heads/" + pr.Head},
+		"toRef":       map[string]string{"id": "refs/heads/" + pr.Base},
+		"draft":       pr.Draft,
+	}
+
+	responseBody, err := request(server.Config, "POST", server.getUrl("/pull-requests"), body)
+	if err != nil {
+		return pr, err
+	}
+
+	created := serverPull{}
+	err = json.Unmarshal(responseBody, &created)
+	return created.toPullRequest(), err
+}
+
+func (server Server) FindPullRequests(prefix string) ([]interfaces.PullRequest, error) {
+	return server.listPullRequests("state=OPEN", func(pr interfaces.PullRequest) bool {
+		return strings.HasPrefix(pr.Head, prefix)
+	})
+}
+
+func (server Server) GetPullRequests(head string) ([]interfaces.PullRequest, error) {
+	return server.listPullRequests("state=ALL&at="+url.QueryEscape("refs/heads/"+head), func(pr interfaces.PullRequest) bool {
+		return pr.Head == head
+	})
+}
+
+func (server Server) listPullRequests(query string, filter func(pr interfaces.PullRequest) bool) ([]interfaces.PullRequest, error) {
+	found := []interfaces.PullRequest{}
+	start := 0
+	for {
+		responseBody, err := request(server.Config, "GET", server.getUrl("/pull-requests?direction=OUTGOING&limit=50&"+query+"&start="+strconv.Itoa(start)), nil)
+		if err != nil {
+			return found, err
+		}
+		page := struct {
+			Values        []serverPull `json:"values"`
+			IsLastPage    bool         `json:"isLastPage"`
+			NextPageStart int          `json:"nextPageStart"`
+		}{}
+		if err := json.Unmarshal(responseBody, &page); err != nil {
+			return found, err
+		}
+		for _, p := range page.Values {
+			if pr := p.toPullRequest(); filter(pr) {
+				found = append(found, pr)
+			}
+		}
+		if page.IsLastPage || len(page.Values) == 0 {
+			return found, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+// UpdatePullRequest sends the current version of the pull request, which the
+// API requires to guard against concurrent edits.
+func (server Server) UpdatePullRequest(pr interfaces.PullRequest) error {
+	current, err := server.getPullRequest(pr.Number)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{"title": pr.Title, "description": pr.Body, "version": current.Version}
+	_, err = request(server.Config, "PUT", server.getUrl("/pull-requests/"+strconv.Itoa(pr.Number)), body)
+	return err
+}
+
+func (server Server) ClosePullRequest(pr interfaces.PullRequest, comment string) error {
+	url := server.getUrl("/pull-requests/" + strconv.Itoa(pr.Number))
+	return decline(server.Config, url+"/comments", map[string]string{"text": comment}, func() (string, error) {
+		current, err := server.getPullRequest(pr.Number)
+		return url + "/decline?version=" + strconv.Itoa(current.Version), err
+	})
+}
+
+// RequestReviewers accepts reviewers by username, Bitbucket Server has no
+// team reviewers.
+func (server Server) RequestReviewers(pr interfaces.PullRequest, reviewers []string, teamReviewers []string) error {
+	server.skipTeamReviewers(teamReviewers)
+	if len(reviewers) == 0 {
+		return nil
+	}
+	current, err := server.getPullRequest(pr.Number)
+	if err != nil {
+		return err
+	}
+	users := []map[string]interface{}{}
+	for _, reviewer := range reviewers {
+		users = append(users, map[string]interface{}{"user": map[string]string{"name": reviewer}})
+	}
+	body := map[string]interface{}{"title": current.Title, "description": current.Description, "version": current.Version, "reviewers": users}
+	_, err = request(server.Config, "PUT", server.getUrl("/pull-requests/"+strconv.Itoa(pr.Number)), body)
+	return err
+}
+
+func (server Server) getPullRequest(number int) (serverPull, error) {
+	current := serverPull{}
+	responseBody, err := request(server.Config, "GET", server.getUrl("/pull-requests/"+strconv.Itoa(number)), nil)
+	if err != nil {
+		return current, err
+	}
+	err = json.Unmarshal(responseBody, &current)
+	return current, err
+}
+
+func (server Server) getUrl(path string) string {
+	return server.ApiUrl + "/projects/" + server.Project + "/repos/" + server.Slug + path
+}
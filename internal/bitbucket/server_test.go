@@ -0,0 +1,116 @@
+package bitbucket
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/fakeapi"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestServerProvider(url string) Server {
+	server, _ := NewServerProvider(&config.Config{Token: "secret"}, url, "scm/PROJ/repo")
+	return server
+}
+
+func TestServerProvider(t *testing.T) {
+	pr := interfaces.PullRequest{Number: 7, Title: "Update akismet", Head: "wpgitupdates-plugin-akismet-4.0-4.1", Base: "main", Body: "Changes", Draft: true}
+	pullUrl := "/rest/api/1.0/projects/PROJ/repos/repo/pull-requests"
+	fakeapi.Run(t, []fakeapi.Case{
+		{
+			Name:      "create",
+			Responses: map[string]string{"POST " + pullUrl: `{"id":7,"version":0,"state":"OPEN","title":"Update akismet","fromRef":{"displayId":"wpgitupdates-plugin-akismet-4.0-4.1"},"toRef":{"displayId":"main"},"links":{"self":[{"href":"https://bitbucket.example.com/projects/PROJ/repos/repo/pull-requests/7"}]}}`},
+			Run: func(url string) (interface{}, error) {
+				return newTestServerProvider(url).CreatePullRequest(pr)
+			},
+			Want: interfaces.PullRequest{Number: 7, Url: "https://bitbucket.example.com/projects/PROJ/repos/repo/pull-requests/7", State: interfaces.PullRequestOpen, Title: "Update akismet", Head: "wpgitupdates-plugin-akismet-4.0-4.1", Base: "main"},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: pullUrl, Body: `{"description":"Changes","draft":true,"fromRef":{"id":"refs/heads/wpgitupdates-plugin-akismet-4.0-4.1"},"title":"Update akismet","toRef":{"id":"refs/heads/main"}}`},
+			},
+		},
+		{
+			Name:      "find",
+			Responses: map[string]string{"GET " + pullUrl: `{"isLastPage":true,"values":[{"id":7,"state":"OPEN","fromRef":{"displayId":"wpgitupdates-plugin-akismet-4.0-4.1"}},{"id":8,"state":"OPEN","fromRef":{"displayId":"feature"}}]}`},
+			Run: func(url string) (interface{}, error) {
+				return newTestServerProvider(url).FindPullRequests("wpgitupdates-plugin-akismet-")
+			},
+			Want: []interfaces.PullRequest{{Number: 7, State: interfaces.PullRequestOpen, Head: "wpgitupdates-plugin-akismet-4.0-4.1"}},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: pullUrl + "?direction=OUTGOING&limit=50&state=OPEN&start=0", Body: ""},
+			},
+		},
+		{
+			Name:      "find by head",
+			Responses: map[string]string{"GET " + pullUrl: `{"isLastPage":true,"values":[{"id":7,"state":"MERGED","fromRef":{"displayId":"wpgitupdates-a"}},{"id":8,"state":"DECLINED","fromRef":{"displayId":"wpgitupdates-a"}}]}`},
+			Run: func(url string) (interface{}, error) {
+				return newTestServerProvider(url).GetPullRequests("wpgitupdates-a")
+			},
+			Want: []interfaces.PullRequest{{Number: 7, State: interfaces.PullRequestMerged, Head: "wpgitupdates-a"}, {Number: 8, State: interfaces.PullRequestClosed, Head: "wpgitupdates-a"}},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: pullUrl + "?direction=OUTGOING&limit=50&state=ALL&at=refs%2Fheads%2Fwpgitupdates-a&start=0", Body: ""},
+			},
+		},
+		{
+			Name:      "update",
+			Responses: map[string]string{"GET " + pullUrl + "/7": `{"id":7,"version":3}`},
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestServerProvider(url).UpdatePullRequest(pr)
+			},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: pullUrl + "/7", Body: ""},
+				{Method: "PUT", Uri: pullUrl + "/7", Body: `{"description":"Changes","title":"Update akismet","version":3}`},
+			},
+		},
+		{
+			Name:      "close",
+			Responses: map[string]string{"GET " + pullUrl + "/7": `{"id":7,"version":3}`},
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestServerProvider(url).ClosePullRequest(pr, "Superseded by #9")
+			},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: pullUrl + "/7/comments", Body: `{"text":"Superseded by #9"}`},
+				{Method: "GET", Uri: pullUrl + "/7", Body: ""},
+				{Method: "POST", Uri: pullUrl + "/7/decline?version=3", Body: ""},
+			},
+		},
+		{
+			Name: "labels",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestServerProvider(url).AddLabels(pr, []string{"dependencies"})
+			},
+			Calls: []fakeapi.Call{},
+		},
+	}, "Authorization", "Bearer secret")
+}
+
+func TestServerProviderPaging(t *testing.T) {
+	pages := map[string]string{
+		"0":  `{"isLastPage":false,"nextPageStart":50,"values":[{"id":7,"state":"OPEN","fromRef":{"displayId":"wpgitupdates-a"}}]}`,
+		"50": `{"isLastPage":true,"values":[{"id":8,"state":"OPEN","fromRef":{"displayId":"wpgitupdates-b"}}]}`,
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(pages[r.URL.Query().Get("start")]))
+	}))
+	defer api.Close()
+
+	p, err := NewServerProvider(&config.Config{Token: "secret"}, api.URL, "PROJ/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := p.FindPullRequests("wpgitupdates-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Number != 7 || found[1].Number != 8 {
+		t.Errorf("got %+v, want pull requests 7 and 8", found)
+	}
+}
+
+func TestNewServerProvider(t *testing.T) {
+	for _, repository := range []string{"PROJ", "scm/PROJ/repo/extra"} {
+		if _, err := NewServerProvider(&config.Config{}, "https://bitbucket.example.com", repository); err == nil {
+			t.Errorf("repository [%s] accepted, want an error", repository)
+		}
+	}
+}
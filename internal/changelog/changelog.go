@@ -0,0 +1,122 @@
+package changelog
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limit is the length each changelog is truncated to, so the changelogs of a
+// few grouped updates still fit in a single pull request body.
+const Limit = 10000
+
+var htmlR = regexp.MustCompile(`(?i)<(p|ul|ol|li|h[1-6]|div|pre)\b`)
+var headingR = regexp.MustCompile(`^(?:#{1,6}\s+(.+?)[\s#]*|=+\s*(.+?)\s*=+|\*\*(.+?)\*\*:?)$`)
+var versionR = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// Entry is the notes of a single version of a changelog, in Markdown.
+type Entry struct {
+	Version string
+	Heading string
+	Notes   string
+}
+
+// Parse splits an HTML or Markdown changelog into entries at each heading
+// naming a version. Anything before the first version heading is dropped.
+func Parse(text string) []Entry {
+	if htmlR.MatchString(text) {
+		text = Markdown(text)
+	}
+
+	entries := []Entry{}
+	notes := []string{}
+	flush := func() {
+		if len(entries) > 0 {
+			entries[len(entries)-1].Notes = strings.TrimSpace(strings.Join(notes, "\n"))
+		}
+		notes = []string{}
+	}
+
+	fenced := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			fenced = !fenced
+		} else if !fenced {
+			if match := headingR.FindStringSubmatch(trimmed); match != nil {
+				heading := match[1] + match[2] + match[3]
+				if version := versionR.FindString(heading); version != "" {
+					flush()
+					entries = append(entries, Entry{Version: version, Heading: heading})
+					continue
+				}
+			}
+		}
+		notes = append(notes, line)
+	}
+	flush()
+
+	return entries
+}
+
+// Between returns the entries of versions after installed up to and
+// including target.
+func Between(entries []Entry, installed string, target string) []Entry {
+	between := []Entry{}
+	for _, entry := range entries {
+		if utils.VersionCompare(entry.Version, target, "<=") && (installed == "" || utils.VersionCompare(installed, entry.Version, "<")) {
+			between = append(between, entry)
+		}
+	}
+	return between
+}
+
+// Extract returns the Markdown notes of the versions after installed up to
+// and including target, truncated to Limit with a link to the full
+// changelog. Changelogs without version headings are included whole.
+func Extract(text string, installed string, target string, link string) string {
+	entries := Parse(text)
+	notes := ""
+	complete := true
+	if len(entries) == 0 {
+		if htmlR.MatchString(text) {
+			text = Markdown(text)
+		}
+		notes = strings.TrimSpace(text)
+	} else {
+		for _, entry := range Between(entries, installed, target) {
+			notes += "#### " + entry.Heading + "\n\n" + entry.Notes + "\n\n"
+		}
+		notes = strings.TrimSpace(notes)
+		if notes == "" {
+			notes = "No changelog entries were found for versions after " + installed + " up to " + target + "."
+			complete = false
+		}
+	}
+
+	if len(notes) > Limit {
+		notes = Truncate(notes, Limit) + "\n\n_Changelog truncated._"
+		complete = false
+	}
+	if !complete && link != "" {
+		notes += " [View the full changelog](" + link + ")"
+	}
+	return notes
+}
+
+// Truncate cuts Markdown at the last line ending within limit, closing any
+// code block left open.
+func Truncate(text string, limit int) string {
+	cut := text[:limit]
+	if i := strings.LastIndex(cut, "\n"); i > 0 {
+		cut = cut[:i]
+	}
+	for !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	if strings.Count(cut, "```")%2 == 1 {
+		cut += "\n```"
+	}
+	return strings.TrimSpace(cut)
+}
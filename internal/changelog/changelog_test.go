@@ -0,0 +1,143 @@
+package changelog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const readme = `Introduction dropped.
+
+= 1.3 =
+* Added export.
+
+= 1.2.1 - 2021-05-01 =
+* Fixed import.
+
+` + "```" + `
+= 2.0 =
+` + "```" + `
+
+= 1.2 =
+* Initial release.`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Entry
+	}{
+		{
+			name: "readme",
+			text: readme,
+			want: []Entry{
+				{Version: "1.3", Heading: "1.3", Notes: "* Added export."},
+				{Version: "1.2.1", Heading: "1.2.1 - 2021-05-01", Notes: "* Fixed import.\n\n```\n= 2.0 =\n```"},
+				{Version: "1.2", Heading: "1.2", Notes: "* Initial release."},
+			},
+		},
+		{
+			name: "markdown",
+			text: "# Changelog\n\n## [2.0.0] ##\n- Breaking\n\n**1.0.0**:\n- First",
+			want: []Entry{
+				{Version: "2.0.0", Heading: "[2.0.0]", Notes: "- Breaking"},
+				{Version: "1.0.0", Heading: "1.0.0", Notes: "- First"},
+			},
+		},
+		{
+			name: "html",
+			text: "<h4>1.1</h4><ul><li>Fix</li></ul><h4>1.0</h4><p>First</p>",
+			want: []Entry{
+				{Version: "1.1", Heading: "1.1", Notes: "- Fix"},
+				{Version: "1.0", Heading: "1.0", Notes: "First"},
+			},
+		},
+		{
+			name: "no headings",
+			text: "Fixed things.",
+			want: []Entry{},
+		},
+	}
+
+	for _, test := range tests {
+		if got := Parse(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	long := "= 2.0 =\n" + strings.Repeat("* A change to something.\n", Limit/10)
+	tests := []struct {
+		name      string
+		text      string
+		installed string
+		target    string
+		link      string
+		want      string
+	}{
+		{
+			name:      "range",
+			text:      readme,
+			installed: "1.2",
+			target:    "1.3",
+			link:      "https://example.com",
+			want:      "#### 1.3\n\n* Added export.\n\n#### 1.2.1 - 2021-05-01\n\n* Fixed import.\n\n```\n= 2.0 =\n```",
+		},
+		{
+			name:      "installed is target",
+			text:      readme,
+			installed: "1.2.1",
+			target:    "1.2.1",
+			want:      "No changelog entries were found for versions after 1.2.1 up to 1.2.1.",
+		},
+		{
+			name:      "none in range",
+			text:      readme,
+			installed: "1.3",
+			target:    "1.4",
+			link:      "https://example.com",
+			want:      "No changelog entries were found for versions after 1.3 up to 1.4. [View the full changelog](https://example.com)",
+		},
+		{
+			name:      "no headings",
+			text:      "<p>Fixed <b>things</b>.</p>",
+			installed: "1.0",
+			target:    "1.1",
+			link:      "https://example.com",
+			want:      "Fixed **things**.",
+		},
+	}
+
+	for _, test := range tests {
+		if got := Extract(test.text, test.installed, test.target, test.link); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	got := Extract(long, "1.0", "2.0", "https://example.com")
+	if len(got) > Limit+100 || !strings.HasSuffix(got, "\n\n_Changelog truncated._ [View the full changelog](https://example.com)") {
+		t.Errorf("long changelog not truncated with a link, got %d bytes ending %q", len(got), got[len(got)-80:])
+	}
+	if !strings.HasSuffix(strings.TrimSuffix(got, "\n\n_Changelog truncated._ [View the full changelog](https://example.com)"), "* A change to something.") {
+		t.Error("long changelog not truncated at a line ending")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"one\ntwo\nthree", 9, "one\ntwo"},
+		{"one\n```\ncode\nmore", 14, "one\n```\ncode\n```"},
+		{"é\né", 4, "é"},
+	}
+
+	for _, test := range tests {
+		if got := Truncate(test.text, test.limit); got != test.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", test.text, test.limit, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package changelog
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var tagR = regexp.MustCompile(`(?s)<!--.*?-->|<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:[^>"']|"[^"]*"|'[^']*')*)>`)
+var hrefR = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+var spaceR = regexp.MustCompile(`\s+`)
+var blankR = regexp.MustCompile(`\n{3,}`)
+
+// list is an open ul or ol element and the number of its items so far.
+type list struct {
+	ordered bool
+	items   int
+}
+
+// converter writes Markdown for the tags and text of an HTML fragment.
+type converter struct {
+	out   strings.Builder
+	lists []list
+	links []string
+	pre   int
+}
+
+// Markdown converts the HTML of changelogs, as rendered by wordpress.org from
+// readme.txt files, to Markdown. Unsupported tags are dropped keeping their
+// text.
+func Markdown(fragment string) string {
+	c := converter{}
+	last := 0
+	for _, match := range tagR.FindAllStringSubmatchIndex(fragment, -1) {
+		c.text(fragment[last:match[0]])
+		last = match[1]
+		if match[4] < 0 {
+			// Comment
+			continue
+		}
+		closing := match[3] > match[2]
+		c.tag(strings.ToLower(fragment[match[4]:match[5]]), fragment[match[6]:match[7]], closing)
+	}
+	c.text(fragment[last:])
+
+	lines := strings.Split(c.out.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(blankR.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+func (c *converter) text(text string) {
+	if text == "" {
+		return
+	}
+	if c.pre > 0 {
+		c.out.WriteString(html.UnescapeString(text))
+		return
+	}
+	text = spaceR.ReplaceAllString(text, " ")
+	if c.atLineStart() {
+		text = strings.TrimLeft(text, " ")
+	}
+	c.out.WriteString(html.UnescapeString(text))
+}
+
+func (c *converter) tag(name string, attributes string, closing bool) {
+	switch name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		c.block()
+		if !closing {
+			level, _ := strconv.Atoi(name[1:])
+			c.out.WriteString(strings.Repeat("#", level) + " ")
+		}
+	case "p", "div", "blockquote", "table", "tr":
+		c.block()
+	case "br":
+		c.newline()
+	case "hr":
+		c.block()
+		c.out.WriteString("---")
+		c.block()
+	case "ul", "ol":
+		if closing {
+			if len(c.lists) > 0 {
+				c.lists = c.lists[:len(c.lists)-1]
+			}
+			if len(c.lists) == 0 {
+				c.block()
+			}
+			return
+		}
+		if len(c.lists) == 0 {
+			c.block()
+		}
+		c.lists = append(c.lists, list{ordered: name == "ol"})
+	case "li":
+		if closing {
+			return
+		}
+		c.newline()
+		if len(c.lists) == 0 {
+			c.out.WriteString("- ")
+			return
+		}
+		current := &c.lists[len(c.lists)-1]
+		current.items++
+		c.out.WriteString(strings.Repeat("  ", len(c.lists)-1))
+		if current.ordered {
+			c.out.WriteString(strconv.Itoa(current.items) + ". ")
+		} else {
+			c.out.WriteString("- ")
+		}
+	case "strong", "b":
+		c.out.WriteString("**")
+	case "em", "i":
+		c.out.WriteString("_")
+	case "code":
+		if c.pre == 0 {
+			c.out.WriteString("`")
+		}
+	case "pre":
+		if closing {
+			if c.pre == 0 {
+				return
+			}
+			c.newline()
+			c.pre--
+			c.out.WriteString("```")
+			c.block()
+			return
+		}
+		c.block()
+		c.pre++
+		c.out.WriteString("```\n")
+	case "a":
+		if closing {
+			if len(c.links) == 0 {
+				return
+			}
+			href := c.links[len(c.links)-1]
+			c.links = c.links[:len(c.links)-1]
+			if href != "" {
+				c.out.WriteString("](" + href + ")")
+			}
+			return
+		}
+		href := ""
+		if match := hrefR.FindStringSubmatch(attributes); match != nil {
+			href = html.UnescapeString(match[1] + match[2])
+		}
+		c.links = append(c.links, href)
+		if href != "" {
+			c.out.WriteString("[")
+		}
+	}
+}
+
+func (c *converter) atLineStart() bool {
+	out := c.out.String()
+	return out == "" || strings.HasSuffix(out, "\n")
+}
+
+// newline ends the current line, unless already at the start of one.
+func (c *converter) newline() {
+	if !c.atLineStart() {
+		c.out.WriteString("\n")
+	}
+}
+
+// block separates block elements with a blank line.
+func (c *converter) block() {
+	out := c.out.String()
+	if out == "" || strings.HasSuffix(out, "\n\n") {
+		return
+	}
+	c.newline()
+	c.out.WriteString("\n")
+}
@@ -0,0 +1,53 @@
+package changelog
+
+import "testing"
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "headings and paragraphs",
+			html: "<h4>1.2</h4>\n<p>Fixed   the\nsettings page.</p>",
+			want: "#### 1.2\n\nFixed the settings page.",
+		},
+		{
+			name: "lists",
+			html: "<ul>\n<li>Added <strong>export</strong></li>\n<li>Fixed <em>import</em></li>\n</ul>\n<ol><li>One</li><li>Two</li></ol>",
+			want: "- Added **export**\n- Fixed _import_\n\n1. One\n2. Two",
+		},
+		{
+			name: "nested lists",
+			html: "<ul><li>Fixes<ul><li>One</li><li>Two</li></ul></li><li>Tweaks</li></ul>",
+			want: "- Fixes\n  - One\n  - Two\n- Tweaks",
+		},
+		{
+			name: "pre",
+			html: "<p>Use:</p><pre><code>add_filter( 'a',  'b' );\nreturn &lt;b&gt;;</code></pre><p>Done</p>",
+			want: "Use:\n\n```\nadd_filter( 'a',  'b' );\nreturn <b>;\n```\n\nDone",
+		},
+		{
+			name: "links",
+			html: `<p>See <a href="https://example.com/?a=1&amp;b=2">the docs</a> and <a name="top">here</a>.</p>`,
+			want: "See [the docs](https://example.com/?a=1&b=2) and here.",
+		},
+		{
+			name: "entities, comments and unsupported tags",
+			html: "<p>Tom &amp; Jerry&#8217;s <span class=\"x\">fix</span><!-- <b>hidden</b> --> <code>a&lt;b</code></p>",
+			want: "Tom & Jerry’s fix `a<b`",
+		},
+		{
+			name: "line breaks and rules",
+			html: "<p>One<br>Two<br/>Three</p><hr><p>Four</p>",
+			want: "One\nTwo\nThree\n\n---\n\nFour",
+		},
+	}
+
+	for _, test := range tests {
+		if got := Markdown(test.html); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -1,145 +1,582 @@
 package config
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
 	"github.com/wpgitupdater/wpgitupdater/internal/constants"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"github.com/wpgitupdater/wpgitupdater/internal/policy"
 	"github.com/wpgitupdater/wpgitupdater/internal/utils"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"text/template"
 )
 
-type PluginConfig struct {
-	Enabled bool
-	Path    string
+type SourceConfig struct {
+	Type       string
+	Url        string
+	VersionUrl string `yaml:"version_url"`
+	Path       string
+	Repository string
+	Asset      string
+}
+
+// BumpConfig applies to the pull requests of updates of a bump level.
+type BumpConfig struct {
+	Draft     bool
+	Automerge string
+}
+
+type OverrideConfig struct {
+	Enabled *bool
 	Commit  string
 	Title   string
-	Include []string
-	Exclude []string
+	Branch  string
+	Labels  []string
+	Policy  string
+	Bumps   map[string]BumpConfig
+	Source  SourceConfig
+}
+
+type ResourceConfig struct {
+	Enabled       bool
+	Path          string
+	Commit        string
+	Title         string
+	Labels        []string
+	Reviewers     []string
+	TeamReviewers []string `yaml:"team_reviewers"`
+	Assignees     []string
+	Milestone     string
+	Include       []string
+	Exclude       []string
+	Policy        string
+	MinAge        int `yaml:"min_age"`
+	Bumps         map[string]BumpConfig
+	Template      string
+	Overrides     map[string]OverrideConfig
+}
+
+type PluginConfig struct {
+	ResourceConfig `yaml:",inline"`
 }
 
 type ThemeConfig struct {
-	Enabled bool
-	Path    string
-	Commit  string
-	Title   string
-	Include []string
-	Exclude []string
+	ResourceConfig `yaml:",inline"`
+}
+
+type CoreConfig struct {
+	ResourceConfig `yaml:",inline"`
+}
+
+// groupSlugR matches the characters of group names not used in branch names.
+var groupSlugR = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+type GroupConfig struct {
+	Name  string
+	Kind  string
+	Bump  string
+	Slugs []string
+	Title string
+}
+
+type ProviderConfig struct {
+	Type     string
+	Host     string
+	Url      string
+	Username string
+}
+
+type EnvironmentConfig struct {
+	PHP       string
+	WordPress string
 }
 
 type Config struct {
-	Cwd          string
-	Branch       string
-	Version      string
-	Token        string
-	UpdaterToken string
-	Plugins      PluginConfig
-	Themes       ThemeConfig
+	Cwd           string
+	Branch        string
+	Policy        string
+	Version       string
+	Token         string
+	UpdaterToken  string
+	GitHubToken   string
+	Plugins       PluginConfig
+	Themes        ThemeConfig
+	Core          CoreConfig
+	Groups        []GroupConfig
+	Environment   EnvironmentConfig
+	Compatibility string
+	GitAuth       string `yaml:"git_auth"`
+	Provider      ProviderConfig
+	Superseded    string
+	Labels        []string
+	Reviewers     []string
+	TeamReviewers []string `yaml:"team_reviewers"`
+	Assignees     []string
+	Milestone     string
+	Template      string
 }
 
-func CreateConfigTemplate() {
+func CreateConfigTemplate() error {
 	template := `version: "` + constants.ConfigVersion + `"
 plugins:
   enabled: true
   path: plugins
 themes:
   enabled: true
-  path: themes`
+  path: themes
+core:
+  enabled: false
+  path: .`
 	if err := ioutil.WriteFile(constants.ConfigFile, []byte(template), 644); err != nil {
-		log.Fatal(err)
+		return err
 	}
-	output := string(utils.RunCmd("chmod", "644", constants.ConfigFile))
-	fmt.Println(output)
+	output, err := utils.RunCmd("chmod", "644", constants.ConfigFile)
+	logger.Println(output)
+	return err
 }
 
-func LoadConfig() Config {
-	plugins := PluginConfig{Path: "plugins"}
-	themes := ThemeConfig{Path: "themes"}
-	config := Config{Cwd: utils.GetCwd(), Token: utils.GetToken(), UpdaterToken: utils.GetUpdaterToken(), Plugins: plugins, Themes: themes}
+func LoadConfig() (Config, error) {
+	plugins := PluginConfig{ResourceConfig{Path: "plugins"}}
+	themes := ThemeConfig{ResourceConfig{Path: "themes"}}
+	config := Config{Plugins: plugins, Themes: themes}
+
+	var err error
+	if config.Cwd, err = utils.GetCwd(); err != nil {
+		return config, err
+	}
+	if config.Token, err = utils.GetToken(); err != nil {
+		return config, err
+	}
+	if config.UpdaterToken, err = utils.GetUpdaterToken(); err != nil {
+		return config, err
+	}
+	config.GitHubToken = utils.GetGitHubToken()
+
 	input, err := ioutil.ReadFile(config.Cwd + "/" + constants.ConfigFile)
 	if err != nil {
-		log.Fatal(err)
+		return config, err
 	}
 
 	if err = yaml.Unmarshal(input, &config); err != nil {
-		log.Fatal(err)
+		return config, err
+	}
+
+	if host := os.Getenv("WP_GIT_UPDATER_PROVIDER_HOST"); host != "" {
+		config.Provider.Host = host
+	}
+	if url := os.Getenv("WP_GIT_UPDATER_PROVIDER_URL"); url != "" {
+		config.Provider.Url = url
 	}
 
 	if _, exists := utils.InSlice(constants.SupportedConfigVersions[:], config.Version); !exists {
 		log.Println("Configuration file version unsupported! Please ensure you match the config with the updaters supported versions.")
 		log.Println("Configuration version [" + config.Version + "]")
 		log.Println("Updater version [" + constants.Version + "]")
-		log.Fatal("Supported configuration versions [" + strings.Join(constants.SupportedConfigVersions[:], ",") + "]")
+		return config, errors.New("Supported configuration versions [" + strings.Join(constants.SupportedConfigVersions[:], ",") + "]")
+	}
+
+	slugs := map[string]bool{}
+	for _, group := range config.Groups {
+		if group.GetSlug() == "" {
+			return config, errors.New("Configuration groups require a name of letters, numbers, dashes or underscores")
+		}
+		if slugs[group.GetSlug()] {
+			return config, errors.New("Configuration group names must be unique, found [" + group.Name + "]")
+		}
+		slugs[group.GetSlug()] = true
+		if _, exists := utils.InSlice([]string{"", constants.PluginResource, constants.ThemeResource, constants.CoreResource}, group.Kind); !exists {
+			return config, errors.New("Configuration group kind must be one of plugin, theme or core, found [" + group.Kind + "]")
+		}
+		if _, exists := utils.InSlice([]string{"", "patch", "minor", "major"}, group.Bump); !exists {
+			return config, errors.New("Configuration group bump must be one of patch, minor or major, found [" + group.Bump + "]")
+		}
 	}
 
-	return config
+	if config.Compatibility != "" && config.Compatibility != "block" && config.Compatibility != "warn" {
+		return config, errors.New("Configuration compatibility must be either block or warn")
+	}
+
+	if _, exists := utils.InSlice([]string{"", "github", "gitlab", "bitbucket", "bitbucket-server", "gitea", "forgejo"}, config.Provider.Type); !exists {
+		return config, errors.New("Configuration provider type must be one of github, gitlab, bitbucket, bitbucket-server, gitea or forgejo")
+	}
+
+	if _, exists := utils.InSlice([]string{"", "askpass", "header", "config"}, config.GitAuth); !exists {
+		return config, errors.New("Configuration git_auth must be one of askpass, header or config")
+	}
+
+	if config.Superseded != "" && config.Superseded != "close" && config.Superseded != "update" {
+		return config, errors.New("Configuration superseded must be either close or update")
+	}
+
+	if err := validatePolicy(config.Policy); err != nil {
+		return config, err
+	}
+
+	// Core versions are looked up without their release dates
+	if config.Core.MinAge != 0 {
+		return config, errors.New("Configuration min_age is not supported for core, release dates of core versions are unknown")
+	}
+
+	for _, kind := range []string{constants.PluginResource, constants.ThemeResource, constants.CoreResource} {
+		if err := validateBumps(config.GetResourceConfig(kind).Bumps); err != nil {
+			return config, err
+		}
+		if err := validatePolicy(config.GetResourceConfig(kind).Policy); err != nil {
+			return config, err
+		}
+		for slug, override := range config.GetResourceConfig(kind).Overrides {
+			if err := validateBumps(override.Bumps); err != nil {
+				return config, err
+			}
+			if err := validateSource(slug, override.Source); err != nil {
+				return config, err
+			}
+			if err := validatePolicy(override.Policy); err != nil {
+				return config, err
+			}
+		}
+		if _, err := config.GetTemplate(kind); err != nil {
+			return config, errors.New("Configuration template is invalid, " + err.Error())
+		}
+		config.mergeOverrides(kind)
+	}
+
+	return config, nil
+}
+
+func validatePolicy(rule string) error {
+	if err := policy.Parse(rule); err != nil {
+		return errors.New("Configuration policy must be patch, minor, major or a version constraint, " + err.Error())
+	}
+	return nil
 }
 
-func (config Config) GetPluginsPath(append string) string {
-	path := config.Cwd + "/" + strings.Trim(config.Plugins.Path, "/")
+// validateSource checks the type of a custom source and the options it
+// requires.
+func validateSource(slug string, source SourceConfig) error {
+	valid, requires := true, ""
+	switch source.Type {
+	case "", "wordpress":
+	case "manifest":
+		valid, requires = source.Url != "", "a url"
+	case "url":
+		valid, requires = source.Url != "" && source.VersionUrl != "", "a url and version_url"
+	case "directory":
+		valid, requires = source.Path != "", "a path"
+	case "github":
+		parts := strings.Split(source.Repository, "/")
+		valid, requires = len(parts) == 2 && parts[0] != "" && parts[1] != "", "a repository in the form owner/repo"
+	default:
+		return errors.New("Configuration source type must be one of wordpress, manifest, url, directory or github, found [" + source.Type + "]")
+	}
+	if !valid {
+		return errors.New("Configuration source of [" + slug + "] with type " + source.Type + " requires " + requires)
+	}
+	return nil
+}
+
+func validateBumps(bumps map[string]BumpConfig) error {
+	for bump, bumpConfig := range bumps {
+		if bump != "patch" && bump != "minor" && bump != "major" {
+			return errors.New("Configuration bumps must be patch, minor or major, found [" + bump + "]")
+		}
+		if _, exists := utils.InSlice([]string{"", "merge", "squash", "rebase"}, bumpConfig.Automerge); !exists {
+			return errors.New("Configuration automerge must be one of merge, squash or rebase")
+		}
+	}
+	return nil
+}
+
+func (config Config) GetResourceConfig(kind string) ResourceConfig {
+	switch kind {
+	case constants.PluginResource:
+		return config.Plugins.ResourceConfig
+	case constants.ThemeResource:
+		return config.Themes.ResourceConfig
+	case constants.CoreResource:
+		return config.Core.ResourceConfig
+	default:
+		return ResourceConfig{}
+	}
+}
+
+func (config Config) GetResourcePath(kind string, append string) string {
+	path := config.Cwd
+	if trimmed := strings.Trim(config.GetResourceConfig(kind).Path, "/"); trimmed != "" && trimmed != "." {
+		path = path + "/" + trimmed
+	}
 	if append != "" {
 		path = path + "/" + strings.Trim(append, "/")
 	}
 	return path
 }
 
-func (config Config) GetPluginsCommit() string {
-	if config.Plugins.Commit != "" {
-		return config.Plugins.Commit
+// mergeOverrides fills in the per slug overrides of a section with the
+// section defaults, so lookups only need to consult the override.
+func (config *Config) mergeOverrides(kind string) {
+	resourceConfig := config.GetResourceConfig(kind)
+	for slug, override := range resourceConfig.Overrides {
+		if override.Commit == "" {
+			override.Commit = resourceConfig.Commit
+		}
+		if override.Title == "" {
+			override.Title = resourceConfig.Title
+		}
+		if override.Branch == "" {
+			override.Branch = config.Branch
+		}
+		if override.Labels == nil {
+			override.Labels = resourceConfig.Labels
+		}
+		if override.Policy == "" {
+			override.Policy = resourceConfig.Policy
+		}
+		if override.Policy == "" {
+			override.Policy = config.Policy
+		}
+		if override.Bumps == nil {
+			override.Bumps = resourceConfig.Bumps
+		}
+		resourceConfig.Overrides[slug] = override
+	}
+}
+
+// GetOverride returns the per slug configuration, populated with the section
+// defaults when the slug has no overrides.
+func (config Config) GetOverride(kind string, slug string) OverrideConfig {
+	resourceConfig := config.GetResourceConfig(kind)
+	if override, exists := resourceConfig.Overrides[slug]; exists {
+		return override
+	}
+	policy := resourceConfig.Policy
+	if policy == "" {
+		policy = config.Policy
+	}
+	return OverrideConfig{Commit: resourceConfig.Commit, Title: resourceConfig.Title, Branch: config.Branch, Labels: resourceConfig.Labels, Policy: policy, Bumps: resourceConfig.Bumps}
+}
+
+func (config Config) GetCommit(kind string, slug string) string {
+	if commit := config.GetOverride(kind, slug).Commit; commit != "" {
+		return commit
+	}
+	switch kind {
+	case constants.CoreResource:
+		return "chore(core): Update WordPress from :oldversion to :newversion"
+	default:
+		return "chore(" + kind + "s): Update :" + kind + " from :oldversion to :newversion"
+	}
+}
+
+func (config Config) GetPRTitle(kind string, slug string) string {
+	if title := config.GetOverride(kind, slug).Title; title != "" {
+		return title
+	}
+	switch kind {
+	case constants.CoreResource:
+		return "Update WordPress core from :oldversion to :newversion"
+	default:
+		return "Update " + kind + " :" + kind + " from :oldversion to :newversion"
+	}
+}
+
+func (config Config) GetBranch(kind string, slug string) string {
+	return config.GetOverride(kind, slug).Branch
+}
+
+func (config Config) GetLabels(kind string, slug string) []string {
+	if labels := config.GetOverride(kind, slug).Labels; labels != nil {
+		return labels
+	}
+	return config.Labels
+}
+
+// GetTemplate parses the template file of a section, or the global one,
+// relative to the repository root. It returns nil when none is configured.
+func (config Config) GetTemplate(kind string) (*template.Template, error) {
+	path := config.GetResourceConfig(kind).Template
+	if path == "" {
+		path = config.Template
 	}
-	return "chore(plugins): Update :plugin from :oldversion to :newversion"
+	if path == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(config.Cwd, path)
+	}
+	return template.ParseFiles(path)
+}
+
+// GetBump returns the pull request options of updates of a bump level, patch,
+// minor or major.
+func (config Config) GetBump(kind string, slug string, bump string) BumpConfig {
+	return config.GetOverride(kind, slug).Bumps[bump]
+}
+
+func (config Config) GetReviewers(kind string) []string {
+	if reviewers := config.GetResourceConfig(kind).Reviewers; reviewers != nil {
+		return reviewers
+	}
+	return config.Reviewers
+}
+
+func (config Config) GetTeamReviewers(kind string) []string {
+	if teams := config.GetResourceConfig(kind).TeamReviewers; teams != nil {
+		return teams
+	}
+	return config.TeamReviewers
+}
+
+func (config Config) GetAssignees(kind string) []string {
+	if assignees := config.GetResourceConfig(kind).Assignees; assignees != nil {
+		return assignees
+	}
+	return config.Assignees
 }
 
-func (config Config) GetPluginsPRTitle() string {
-	if config.Plugins.Title != "" {
-		return config.Plugins.Title
+// GetMilestone returns the title of the milestone update pull requests are
+// added to.
+func (config Config) GetMilestone(kind string) string {
+	if milestone := config.GetResourceConfig(kind).Milestone; milestone != "" {
+		return milestone
 	}
-	return "Update plugin :plugin from :oldversion to :newversion"
+	return config.Milestone
+}
+
+func (config Config) GetPolicy(kind string, slug string) string {
+	return config.GetOverride(kind, slug).Policy
 }
 
-func (config Config) PluginCanBeUpdated(slug string) bool {
-	if len(config.Plugins.Include) > 0 {
-		_, found := utils.InSlice(config.Plugins.Include, slug)
+// GetMinAge returns the number of days a release must have been available for
+// before it is updated to.
+func (config Config) GetMinAge(kind string) int {
+	return config.GetResourceConfig(kind).MinAge
+}
+
+func (config Config) GetSource(kind string, slug string) SourceConfig {
+	return config.GetOverride(kind, slug).Source
+}
+
+func (config Config) CanBeUpdated(kind string, slug string) bool {
+	resourceConfig := config.GetResourceConfig(kind)
+	if override, exists := resourceConfig.Overrides[slug]; exists && override.Enabled != nil {
+		return *override.Enabled
+	}
+	if len(resourceConfig.Include) > 0 {
+		_, found := utils.InSlice(resourceConfig.Include, slug)
 		return found
-	} else if len(config.Plugins.Exclude) > 0 {
-		_, found := utils.InSlice(config.Plugins.Exclude, slug)
+	} else if len(resourceConfig.Exclude) > 0 {
+		_, found := utils.InSlice(resourceConfig.Exclude, slug)
 		return !found
 	} else {
 		return true
 	}
 }
 
-func (config Config) GetThemesPath(append string) string {
-	path := config.Cwd + "/" + strings.Trim(config.Themes.Path, "/")
-	if append != "" {
-		path = path + "/" + strings.Trim(append, "/")
+// GetPHPVersion returns the configured PHP version of the site, falling back
+// to the platform or required PHP version declared in composer.json.
+func (config Config) GetPHPVersion() string {
+	if config.Environment.PHP != "" {
+		return config.Environment.PHP
 	}
-	return path
+
+	input, err := ioutil.ReadFile(config.Cwd + "/composer.json")
+	if err != nil {
+		return ""
+	}
+	composer := struct {
+		Require map[string]string `json:"require"`
+		Config  struct {
+			Platform map[string]string `json:"platform"`
+		} `json:"config"`
+	}{}
+	if err := json.Unmarshal(input, &composer); err != nil {
+		return ""
+	}
+	if php := composer.Config.Platform["php"]; php != "" {
+		return php
+	}
+	// Use the lowest version permitted by a constraint such as "^7.4" or ">=7.4"
+	return regexp.MustCompile(`[0-9]+(\.[0-9]+)*`).FindString(composer.Require["php"])
 }
 
-func (config Config) GetThemesCommit() string {
-	if config.Themes.Commit != "" {
-		return config.Themes.Commit
+// GetWordPressVersion returns the configured WordPress version of the site,
+// falling back to the version of core within the repository.
+func (config Config) GetWordPressVersion() string {
+	if config.Environment.WordPress != "" {
+		return config.Environment.WordPress
 	}
-	return "chore(themes): Update :theme from :oldversion to :newversion"
+	version, err := utils.GetWordPressVersion(config.GetResourcePath(constants.CoreResource, "wp-includes/version.php"))
+	if err != nil {
+		return ""
+	}
+	return version
 }
 
-func (config Config) GetThemesPRTitle() string {
-	if config.Themes.Title != "" {
-		return config.Themes.Title
+// GetGitAuth returns how git is authenticated against the origin, askpass
+// unless configured otherwise.
+func (config Config) GetGitAuth() string {
+	if config.GitAuth == "" {
+		return "askpass"
 	}
-	return "Update theme :theme from :oldversion to :newversion"
+	return config.GitAuth
 }
 
-func (config Config) ThemeCanBeUpdated(slug string) bool {
-	if len(config.Themes.Include) > 0 {
-		_, found := utils.InSlice(config.Themes.Include, slug)
-		return found
-	} else if len(config.Themes.Exclude) > 0 {
-		_, found := utils.InSlice(config.Themes.Exclude, slug)
-		return !found
-	} else {
+// UpdatesSuperseded reports whether a newer version is pushed onto the branch
+// of an open pull request for the same resources, rather than opening a new
+// pull request and closing the old one.
+func (config Config) UpdatesSuperseded() bool {
+	return config.Superseded == "update"
+}
+
+// BlocksIncompatible reports whether updates whose requirements exceed the
+// site environment are held back, rather than flagged in the pull request.
+func (config Config) BlocksIncompatible() bool {
+	return config.Compatibility != "warn"
+}
+
+func (config Config) GetGroup(kind string, slug string, bump string) (GroupConfig, bool) {
+	for _, group := range config.Groups {
+		if group.Matches(kind, slug, bump) {
+			return group, true
+		}
+	}
+	return GroupConfig{}, false
+}
+
+func (group GroupConfig) Matches(kind string, slug string, bump string) bool {
+	if group.Kind != "" && group.Kind != kind {
+		return false
+	}
+	if len(group.Slugs) > 0 {
+		if _, found := utils.InSlice(group.Slugs, slug); !found {
+			return false
+		}
+	}
+	switch group.Bump {
+	case "patch":
+		return bump == "patch"
+	case "minor":
+		return bump == "patch" || bump == "minor"
+	default:
 		return true
 	}
 }
+
+// GetSlug is the name of the group as used in branch names, with anything
+// other than letters, numbers, dashes and underscores replaced by dashes.
+func (group GroupConfig) GetSlug() string {
+	return strings.Trim(groupSlugR.ReplaceAllString(group.Name, "-"), "-")
+}
+
+func (group GroupConfig) GetPRTitle() string {
+	if group.Title != "" {
+		return group.Title
+	}
+	return "Update :count resources in group :group"
+}
@@ -3,10 +3,14 @@ package constants
 var Build = "@dev-build"
 var BuildDate = ""
 var Version = "@dev-version"
-var SupportedConfigVersions = [1]string{"1.0"}
+var SupportedConfigVersions = [2]string{"1.0", "1.1"}
+
+const PluginResource = "plugin"
+const ThemeResource = "theme"
+const CoreResource = "core"
 
 const ConfigFile = ".wpgitupdater.yml"
-const ConfigVersion = "1.0"
+const ConfigVersion = "1.1"
 const GitUser = "WP Git Updater Bot"
 const GitEmail = "bot@wpgitupdater.dev"
 const UserAgent = "wpgitupdater"
@@ -14,5 +18,9 @@ const WorkflowFile = ".github/workflows/wpgitupdater.yml"
 const InstallerUrl = "https://install.wpgitupdater.dev/install.sh"
 const ApiUrl = "https://wpgitupdater.dev/api/v1"
 
+const WordPressPluginUrl = "https://wordpress.org/plugins/"
 const WordPressPluginApiInfo = "https://api.wordpress.org/plugins/info/1.2/?action=plugin_information&request[slug]="
 const WordPressThemeApiInfo = "https://api.wordpress.org/themes/info/1.2/?action=theme_information&request[slug]="
+const WordPressPluginChecksums = "https://downloads.wordpress.org/plugin-checksums/"
+const WordPressCoreChecksums = "https://api.wordpress.org/core/checksums/1.0/?version="
+const WordPressCoreApiVersionCheck = "https://api.wordpress.org/core/version-check/1.7/?version="
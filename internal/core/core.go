@@ -0,0 +1,214 @@
+package core
+
+import (
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/constants"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"github.com/wpgitupdater/wpgitupdater/internal/source"
+	"github.com/wpgitupdater/wpgitupdater/internal/updater"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Core files and directories that must never be replaced by an update.
+var preserved = []string{"wp-content", "wp-config.php"}
+
+type CoreOffer struct {
+	Response string `json:"response"`
+	Download string `json:"download"`
+	Version  string `json:"version"`
+	PHP      string `json:"php_version"`
+	Packages struct {
+		Full      string `json:"full"`
+		NoContent string `json:"no_content"`
+	} `json:"packages"`
+}
+
+type CoreInfo struct {
+	Offers []CoreOffer `json:"offers"`
+}
+
+type Core struct {
+	Path    string
+	Version string
+	Offer   CoreOffer
+}
+
+func GetCore(cnf *config.Config) (Core, error) {
+	logger.Println("Collecting core information")
+	version, err := utils.GetWordPressVersion(cnf.GetResourcePath(constants.CoreResource, "wp-includes/version.php"))
+	if err != nil {
+		return Core{}, err
+	}
+
+	logger.Println(fmt.Sprintf("[core] WordPress %s found", version))
+	core := Core{Path: cnf.GetResourcePath(constants.CoreResource, ""), Version: version}
+
+	logger.Println("[core] loading external core info")
+	info := CoreInfo{}
+	if err := utils.LoadWordPressApiInfo(constants.WordPressCoreApiVersionCheck+version, &info); err != nil {
+		return core, fmt.Errorf("unable to load core info: %s", err)
+	}
+	offers := map[string]CoreOffer{}
+	available := []string{}
+	for _, offer := range info.Offers {
+		if offer.Response == "upgrade" || offer.Response == "latest" || offer.Response == "autoupdate" {
+			offers[offer.Version] = offer
+			available = append(available, offer.Version)
+		}
+	}
+	core.Offer = offers[updater.SelectVersion(cnf, constants.CoreResource, core.GetSlug(), version, available, nil)]
+
+	return core, nil
+}
+
+func ListCore(cnf *config.Config) {
+	core, err := GetCore(cnf)
+	if err != nil {
+		logger.Printf("[core] %s, skipping\n", err)
+		report := updater.Report{}
+		report.Add(constants.CoreResource, "wordpress", err, "")
+		updater.List(cnf, []interfaces.Resource{}, report)
+		return
+	}
+	updater.List(cnf, []interfaces.Resource{core}, updater.Report{})
+}
+
+func (core Core) GetKind() string {
+	return constants.CoreResource
+}
+
+func (core Core) GetSlug() string {
+	return "wordpress"
+}
+
+func (core Core) GetName() string {
+	return "WordPress"
+}
+
+func (core Core) GetInstalledVersion() string {
+	return core.Version
+}
+
+func (core Core) GetAvailableVersion() string {
+	return core.Offer.Version
+}
+
+func (core Core) GetDownloadUrl() string {
+	if core.Offer.Packages.NoContent != "" {
+		return core.Offer.Packages.NoContent
+	}
+	return core.Offer.Download
+}
+
+func (core Core) GetInstallDir() string {
+	return core.Path
+}
+
+func (core Core) GetHomePage() string {
+	return "https://wordpress.org/download/releases/"
+}
+
+func (core Core) GetLastUpdated() string {
+	return ""
+}
+
+func (core Core) GetReleaseDate() string {
+	return ""
+}
+
+func (core Core) GetChangelog() string {
+	return "Release notes for WordPress " + core.Offer.Version + " are available at https://wordpress.org/documentation/wordpress-version/version-" + strings.ReplaceAll(core.Offer.Version, ".", "-") + "/"
+}
+
+func (core Core) GetRequirements() interfaces.Requirements {
+	return interfaces.Requirements{PHP: core.Offer.PHP}
+}
+
+// GetChecksums leaves out the preserved files, which an update never replaces.
+func (core Core) GetChecksums() (map[string][]string, error) {
+	checksums, err := source.WordPress{Kind: constants.CoreResource}.GetChecksums(core.GetSlug(), core.Offer.Version)
+	if err != nil || checksums == nil {
+		return checksums, err
+	}
+	for name := range checksums {
+		if utils.InPaths(preserved, name) {
+			delete(checksums, name)
+		}
+	}
+	return checksums, nil
+}
+
+func (core Core) GetPreserved() []string {
+	return preserved
+}
+
+// Validate checks the staged copy is the WordPress version being installed.
+func (core Core) Validate(staged string) error {
+	version, err := utils.GetWordPressVersion(filepath.Join(staged, "wp-includes", "version.php"))
+	if err != nil {
+		return fmt.Errorf("staged core has no version, %s", err)
+	}
+	if !utils.VersionCompare(version, core.GetAvailableVersion(), "==") {
+		return fmt.Errorf("staged core is version %s, expected %s", version, core.GetAvailableVersion())
+	}
+	return nil
+}
+
+// Install copies the core directories and root files beside the installed
+// ones and swaps each into place by rename, leaving wp-content and
+// wp-config.php untouched. Entries already swapped are put back on failure.
+func (core Core) Install(staged string) error {
+	swap, err := ioutil.TempDir(core.Path, ".wordpress-wpgitupdater-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(swap)
+
+	incoming := filepath.Join(swap, "new")
+	previous := filepath.Join(swap, "old")
+	for _, dir := range []string{incoming, previous} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	if err := utils.CopyDir(staged, incoming, preserved); err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(incoming)
+	if err != nil {
+		return err
+	}
+	swapped := []string{}
+	for _, entry := range entries {
+		dest := filepath.Join(core.Path, entry.Name())
+		if err := os.Rename(dest, filepath.Join(previous, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return core.restore(previous, swapped, err)
+		}
+		swapped = append(swapped, entry.Name())
+		if err := os.Rename(filepath.Join(incoming, entry.Name()), dest); err != nil {
+			return core.restore(previous, swapped, err)
+		}
+	}
+	return nil
+}
+
+// restore puts back the entries Install moved aside before failing with err.
+func (core Core) restore(previous string, swapped []string, err error) error {
+	for _, name := range swapped {
+		dest := filepath.Join(core.Path, name)
+		if removeErr := os.RemoveAll(dest); removeErr != nil {
+			return fmt.Errorf("%s, unable to restore %s: %s", err, dest, removeErr)
+		}
+		if restoreErr := os.Rename(filepath.Join(previous, name), dest); restoreErr != nil && !os.IsNotExist(restoreErr) {
+			return fmt.Errorf("%s, unable to restore %s: %s", err, dest, restoreErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package fakeapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Call is a request received by a fake API, with its body trimmed.
+type Call struct {
+	Method string
+	Uri    string
+	Body   string
+}
+
+// Server is a fake provider API for tests, answering each request with the
+// response registered for its method and path, such as
+// "GET /repos/owner/repo/pulls", or an empty object.
+type Server struct {
+	*httptest.Server
+	Calls   []Call
+	Headers []http.Header
+}
+
+func New(responses map[string]string) *Server {
+	api := &Server{Calls: []Call{}}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		api.Calls = append(api.Calls, Call{r.Method, r.RequestURI, strings.TrimSpace(string(body))})
+		api.Headers = append(api.Headers, r.Header)
+		response, exists := responses[r.Method+" "+r.URL.Path]
+		if !exists {
+			response = "{}"
+		}
+		w.Write([]byte(response))
+	}))
+	return api
+}
+
+// Case is a provider method called against a fake API, run with its url,
+// along with the result and requests expected.
+type Case struct {
+	Name      string
+	Responses map[string]string
+	Run       func(url string) (interface{}, error)
+	Want      interface{}
+	Calls     []Call
+}
+
+// Run runs every case against a fake API of its own, checking each request
+// sent header with value when header is given.
+func Run(t *testing.T, cases []Case, header string, value string) {
+	for _, test := range cases {
+		t.Run(test.Name, func(t *testing.T) {
+			api := New(test.Responses)
+			defer api.Close()
+
+			got, err := test.Run(api.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.Want) {
+				t.Errorf("got %+v, want %+v", got, test.Want)
+			}
+			if !reflect.DeepEqual(api.Calls, test.Calls) {
+				t.Errorf("requests %+v, want %+v", api.Calls, test.Calls)
+			}
+			for _, headers := range api.Headers {
+				if header != "" && headers.Get(header) != value {
+					t.Errorf("%s [%s], want [%s]", header, headers.Get(header), value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package git
+
+import (
+	"encoding/base64"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/constants"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// askpass answers git credential prompts from the environment, so the token
+// itself is never written to disk.
+const askpass = `#!/bin/sh
+case "$1" in
+Username*) echo "$WP_GIT_UPDATER_GIT_USERNAME" ;;
+*) echo "${WP_GIT_UPDATER_GIT_TOKEN#*:}" ;;
+esac
+`
+
+var sshR = regexp.MustCompile(`^(?:git\+)?ssh://[^/]+/`)
+
+// environment is added to every git invocation made through Run.
+var environment []string
+
+// Run runs git with the authentication and committer identity set up by
+// Configure.
+func Run(args ...string) (string, error) {
+	return utils.RunCmdEnv(environment, append([]string{"git"}, args...)...)
+}
+
+// Configure sets up authentication for git commands using the configured
+// git_auth mode. The askpass and header modes only pass credentials through
+// the environment of each git invocation and leave .git/config untouched.
+// The returned function undoes the configuration.
+func Configure(cnf *config.Config) (func() error, error) {
+	if cnf.GetGitAuth() == "config" {
+		if err := ConfigureGitConfig(cnf); err != nil {
+			return func() error { return nil }, err
+		}
+		return func() error { return RestoreGitConfig(cnf) }, nil
+	}
+
+	origin, err := utils.RunCmd("git", "remote", "get-url", "origin")
+	if err != nil {
+		return func() error { return nil }, err
+	}
+	remote, err := ResolveRemote(cnf)
+	if err != nil {
+		return func() error { return nil }, err
+	}
+	host := remote.Host
+
+	// Push over https so the token can be used with ssh remotes
+	settings := [][2]string{}
+	origin = strings.TrimSpace(origin)
+	if prefix := sshR.FindString(origin); prefix != "" {
+		settings = append(settings, [2]string{"url." + remote.BaseUrl + "/.insteadOf", prefix})
+	} else if scpR.MatchString(origin) && !strings.Contains(origin, "://") {
+		settings = append(settings, [2]string{"url." + remote.BaseUrl + "/.insteadOf", origin[:strings.Index(origin, ":")+1]})
+	}
+
+	logger.Printf("Configuring git %s authentication\n", cnf.GetGitAuth())
+	environment = []string{
+		"GIT_AUTHOR_NAME=" + constants.GitUser,
+		"GIT_AUTHOR_EMAIL=" + constants.GitEmail,
+		"GIT_COMMITTER_NAME=" + constants.GitUser,
+		"GIT_COMMITTER_EMAIL=" + constants.GitEmail,
+		"GIT_TERMINAL_PROMPT=0",
+		"WP_GIT_UPDATER_GIT_USERNAME=" + getUsername(cnf, host),
+	}
+	cleanup := func() error {
+		environment = nil
+		return nil
+	}
+
+	if cnf.GetGitAuth() == "header" {
+		password := cnf.Token[strings.Index(cnf.Token, ":")+1:]
+		credentials := base64.StdEncoding.EncodeToString([]byte(getUsername(cnf, host) + ":" + password))
+		logger.AddSecret(credentials)
+		settings = append(settings, [2]string{"http." + remote.BaseUrl + "/.extraHeader", "Authorization: Basic " + credentials})
+	} else {
+		dir, err := ioutil.TempDir("", "wpgitupdater-askpass")
+		if err != nil {
+			return cleanup, err
+		}
+		script := filepath.Join(dir, "askpass.sh")
+		if err := ioutil.WriteFile(script, []byte(askpass), 0700); err != nil {
+			os.RemoveAll(dir)
+			return cleanup, err
+		}
+		environment = append(environment, "GIT_ASKPASS="+script)
+		cleanup = func() error {
+			environment = nil
+			return os.RemoveAll(dir)
+		}
+	}
+
+	environment = append(environment, "GIT_CONFIG_COUNT="+strconv.Itoa(len(settings)))
+	for i, setting := range settings {
+		environment = append(environment, "GIT_CONFIG_KEY_"+strconv.Itoa(i)+"="+setting[0], "GIT_CONFIG_VALUE_"+strconv.Itoa(i)+"="+setting[1])
+	}
+
+	return cleanup, nil
+}
+
+// getUsername returns the username sent along with the token, taken from
+// tokens in the form username:password or the conventional token username of
+// the host.
+func getUsername(cnf *config.Config, host string) string {
+	if cnf.Provider.Username != "" {
+		return cnf.Provider.Username
+	}
+	if i := strings.Index(cnf.Token, ":"); i > 0 {
+		return cnf.Token[:i]
+	}
+	if host == "bitbucket.org" {
+		return "x-token-auth"
+	}
+	return "x-access-token"
+}
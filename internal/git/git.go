@@ -4,79 +4,90 @@ import (
 	"fmt"
 	"github.com/wpgitupdater/wpgitupdater/internal/config"
 	"github.com/wpgitupdater/wpgitupdater/internal/constants"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
 	"github.com/wpgitupdater/wpgitupdater/internal/utils"
 	"io/ioutil"
-	"log"
 	"os"
 	"os/exec"
 	"regexp"
 	"strings"
 )
 
-func ConfigureGitConfig(cnf *config.Config) {
+func ConfigureGitConfig(cnf *config.Config) error {
 	gitConfigFile := cnf.Cwd + "/.git/config"
-	fmt.Println(fmt.Sprintf("Configuring git config using token: %s", cnf.Token))
+	logger.Println("Configuring git config")
 
-	fmt.Println("Creating git config backup")
+	logger.Println("Creating git config backup")
 	input, err := ioutil.ReadFile(gitConfigFile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	err = ioutil.WriteFile(gitConfigFile+".original", input, 644)
+	err = ioutil.WriteFile(gitConfigFile+".original", input, 0600)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	fmt.Println("Setting committer email address")
-	output := string(utils.RunCmd("git", "config", "user.email", constants.GitEmail))
+	logger.Println("Setting committer email address")
+	output, err := utils.RunCmd("git", "config", "user.email", constants.GitEmail)
 	if output != "" {
-		fmt.Println(output)
+		logger.Println(output)
+	}
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("Setting committer name")
-	output = string(utils.RunCmd("git", "config", "user.name", constants.GitUser))
+	logger.Println("Setting committer name")
+	output, err = utils.RunCmd("git", "config", "user.name", constants.GitUser)
 	if output != "" {
-		fmt.Println(output)
+		logger.Println(output)
+	}
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("Updating origin url")
-	output = string(utils.RunCmd("git", "remote", "get-url", "origin"))
+	logger.Println("Updating origin url")
+	output, err = utils.RunCmd("git", "remote", "get-url", "origin")
+	if err != nil {
+		return err
+	}
 	url := strings.TrimSpace(output)
 	re := regexp.MustCompile("^(git@|https://)([^:/]+)[:/](.+)")
 	origin := re.ReplaceAllString(url, fmt.Sprintf("https://x-access-token:%v@$2/$3", cnf.Token))
-	output = string(utils.RunCmd("git", "remote", "set-url", "origin", origin))
+	output, err = utils.RunCmd("git", "remote", "set-url", "origin", origin)
 	if output != "" {
-		fmt.Println(output)
+		logger.Println(output)
 	}
+	return err
 }
 
-func RestoreGitConfig(cnf *config.Config) {
-	fmt.Println("Restoring git config")
+func RestoreGitConfig(cnf *config.Config) error {
+	logger.Println("Restoring git config")
 	gitConfigFile := cnf.Cwd + "/.git/config"
 	err := os.Remove(gitConfigFile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	err = os.Rename(gitConfigFile+".original", gitConfigFile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	utils.RunCmd("chmod", "644", gitConfigFile)
+	return os.Chmod(gitConfigFile, 0644)
 }
 
-func CurrentBranch() string {
+func CurrentBranch() (string, error) {
 	cmd := exec.Command("git", "branch", "--show-current")
-	cmd.Dir = utils.GetCwd()
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	return strings.TrimSpace(string(output))
+	return strings.TrimSpace(string(output)), nil
 }
 
 func BranchExists(branch string) bool {
 	cmd := exec.Command("git", "ls-remote", "--exit-code", "--heads", "origin", branch)
-	cmd.Dir = utils.GetCwd()
+	if len(environment) > 0 {
+		cmd.Env = append(os.Environ(), environment...)
+	}
 	_, err := cmd.CombinedOutput()
 	if err != nil {
 		return false
@@ -84,12 +95,24 @@ func BranchExists(branch string) bool {
 	return true
 }
 
-func GetProvider() string {
-	return "github.com"
+// HasChanges reports whether the working tree has uncommitted changes or
+// untracked files.
+func HasChanges() (bool, error) {
+	output, err := utils.RunCmd("git", "status", "--porcelain")
+	return strings.TrimSpace(output) != "", err
 }
 
-func GetRepository() string {
-	output := string(utils.RunCmd("git", "remote", "get-url", "origin"))
-	parts := strings.Split(strings.TrimSpace(output), GetProvider())
-	return strings.Replace(strings.TrimLeft(parts[1], ":/"), ".git", "", 1)
+// RemoteBranches lists the branches of origin matching pattern.
+func RemoteBranches(pattern string) ([]string, error) {
+	output, err := Run("ls-remote", "--heads", "origin", pattern)
+	if err != nil {
+		return nil, err
+	}
+	branches := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if i := strings.Index(line, "refs/heads/"); i >= 0 {
+			branches = append(branches, strings.TrimSpace(line[i+len("refs/heads/"):]))
+		}
+	}
+	return branches, nil
 }
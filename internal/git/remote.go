@@ -0,0 +1,82 @@
+package git
+
+import (
+	"errors"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Remote is the origin remote of the repository. BaseUrl is the web address
+// of the host, including any custom port of https remotes.
+type Remote struct {
+	Host    string
+	Path    string
+	BaseUrl string
+}
+
+// scpR matches scp like remotes, such as git@github.com:owner/repo.git
+var scpR = regexp.MustCompile(`^(?:[^@/:]+@)?([^:/]+):(.+)$`)
+
+// ParseRemote parses ssh, https and scp like remote urls, with or without
+// users, ports, .git suffixes and nested paths such as GitLab subgroups.
+func ParseRemote(origin string) (Remote, error) {
+	origin = strings.TrimSpace(origin)
+	remote := Remote{}
+
+	if match := scpR.FindStringSubmatch(origin); match != nil && !strings.Contains(origin, "://") {
+		remote.Host = strings.ToLower(match[1])
+		remote.Path = match[2]
+		remote.BaseUrl = "https://" + remote.Host
+	} else {
+		parsed, err := url.Parse(origin)
+		if err != nil || parsed.Hostname() == "" {
+			return remote, errors.New("unable to parse origin url")
+		}
+		remote.Host = strings.ToLower(parsed.Hostname())
+		remote.Path = parsed.Path
+		remote.BaseUrl = "https://" + remote.Host
+		// Only http remotes share their port with the web interface
+		if parsed.Scheme == "http" || parsed.Scheme == "https" {
+			remote.BaseUrl = parsed.Scheme + "://" + strings.ToLower(parsed.Host)
+		}
+	}
+
+	remote.Path = strings.Trim(strings.TrimSuffix(strings.Trim(remote.Path, "/"), ".git"), "/")
+	if remote.Path == "" {
+		return remote, errors.New("origin url has no repository path")
+	}
+	return remote, nil
+}
+
+func GetRemote() (Remote, error) {
+	output, err := utils.RunCmd("git", "remote", "get-url", "origin")
+	if err != nil {
+		return Remote{}, err
+	}
+	return ParseRemote(output)
+}
+
+// ResolveRemote returns the origin remote with the host replaced by the
+// configured provider host, for remotes using ssh aliases or web interfaces
+// on another port.
+func ResolveRemote(cnf *config.Config) (Remote, error) {
+	remote, err := GetRemote()
+	if err != nil || cnf.Provider.Host == "" {
+		return remote, err
+	}
+
+	host := cnf.Provider.Host
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	parsed, err := url.Parse(host)
+	if err != nil || parsed.Hostname() == "" {
+		return remote, errors.New("invalid provider host [" + cnf.Provider.Host + "]")
+	}
+	remote.Host = strings.ToLower(parsed.Hostname())
+	remote.BaseUrl = strings.ToLower(parsed.Scheme + "://" + parsed.Host)
+	return remote, nil
+}
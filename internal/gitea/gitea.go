@@ -0,0 +1,98 @@
+package gitea
+
+import (
+	"encoding/json"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/github"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"strconv"
+	"strings"
+)
+
+// Gitea opens pull requests on a Gitea or Forgejo instance, whose API mirrors
+// GitHub apart from labelling pull requests by id.
+type Gitea struct {
+	github.GitHub
+}
+
+func NewProvider(cnf *config.Config, baseUrl string, repository string) Gitea {
+	apiUrl := strings.TrimRight(cnf.Provider.Url, "/")
+	if apiUrl == "" {
+		apiUrl = baseUrl + "/api/v1"
+	}
+	return Gitea{github.GitHub{Config: cnf, ApiUrl: apiUrl, Repository: repository}}
+}
+
+// CreatePullRequest marks drafts with the WIP: title prefix Gitea recognises.
+func (gitea Gitea) CreatePullRequest(pr interfaces.PullRequest) (interfaces.PullRequest, error) {
+	if pr.Draft {
+		pr.Title = "WIP: " + pr.Title
+	}
+	return gitea.GitHub.CreatePullRequest(pr)
+}
+
+func (gitea Gitea) UpdatePullRequest(pr interfaces.PullRequest) error {
+	if pr.Draft {
+		pr.Title = "WIP: " + pr.Title
+	}
+	return gitea.GitHub.UpdatePullRequest(pr)
+}
+
+// GetPullRequests filters every pull request by head, as Gitea ignores the
+// head parameter when listing pull requests.
+func (gitea Gitea) GetPullRequests(head string) ([]interfaces.PullRequest, error) {
+	return gitea.ListPullRequests("state=all", func(pr interfaces.PullRequest) bool {
+		return pr.Head == head
+	})
+}
+
+// AddLabels adds the repository labels matching the given names, names
+// without a matching label are skipped as Gitea cannot create them here.
+func (gitea Gitea) AddLabels(pr interfaces.PullRequest, labels []string) error {
+	responseBody, err := gitea.Request("GET", "/labels?limit=50", nil)
+	if err != nil {
+		return err
+	}
+	existing := []struct {
+		Id   int64  `json:"id"`
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal(responseBody, &existing); err != nil {
+		return err
+	}
+
+	ids := []int64{}
+	for _, label := range labels {
+		found := false
+		for _, candidate := range existing {
+			if strings.EqualFold(candidate.Name, label) {
+				ids = append(ids, candidate.Id)
+				found = true
+				break
+			}
+		}
+		if !found {
+			logger.Printf("Label [%s] does not exist, skipping\n", label)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err = gitea.Request("POST", "/issues/"+strconv.Itoa(pr.Number)+"/labels", map[string][]int64{"labels": ids})
+	return err
+}
+
+// AddAssignees replaces the assignees, Gitea has no endpoint adding them.
+func (gitea Gitea) AddAssignees(pr interfaces.PullRequest, assignees []string) error {
+	_, err := gitea.Request("PATCH", "/issues/"+strconv.Itoa(pr.Number), map[string][]string{"assignees": assignees})
+	return err
+}
+
+// EnableAutoMerge schedules the merge for when the required checks succeed.
+func (gitea Gitea) EnableAutoMerge(pr interfaces.PullRequest, method string) error {
+	body := map[string]interface{}{"Do": method, "merge_when_checks_succeed": true}
+	_, err := gitea.Request("POST", "/pulls/"+strconv.Itoa(pr.Number)+"/merge", body)
+	return err
+}
@@ -0,0 +1,91 @@
+package gitea
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/fakeapi"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"testing"
+)
+
+func newTestProvider(url string) Gitea {
+	return NewProvider(&config.Config{Token: "secret"}, url, "owner/repo")
+}
+
+func TestProvider(t *testing.T) {
+	pr := interfaces.PullRequest{Number: 7, Title: "Update akismet", Head: "wpgitupdates-plugin-akismet-4.0-4.1", Base: "main", Body: "Changes", Draft: true}
+	fakeapi.Run(t, []fakeapi.Case{
+		{
+			Name:      "create",
+			Responses: map[string]string{"POST /api/v1/repos/owner/repo/pulls": `{"number":7,"html_url":"https://gitea.example.com/owner/repo/pulls/7","state":"open","title":"WIP: Update akismet","head":{"ref":"wpgitupdates-plugin-akismet-4.0-4.1"},"base":{"ref":"main"}}`},
+			Run: func(url string) (interface{}, error) {
+				return newTestProvider(url).CreatePullRequest(pr)
+			},
+			Want: interfaces.PullRequest{Number: 7, Url: "https://gitea.example.com/owner/repo/pulls/7", State: interfaces.PullRequestOpen, Title: "WIP: Update akismet", Head: "wpgitupdates-plugin-akismet-4.0-4.1", Base: "main"},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: "/api/v1/repos/owner/repo/pulls", Body: `{"base":"main","body":"Changes","draft":true,"head":"wpgitupdates-plugin-akismet-4.0-4.1","title":"WIP: Update akismet"}`},
+			},
+		},
+		{
+			Name:      "find",
+			Responses: map[string]string{"GET /api/v1/repos/owner/repo/pulls": `[{"number":7,"state":"open","head":{"ref":"wpgitupdates-plugin-akismet-4.0-4.1"}},{"number":8,"state":"open","head":{"ref":"feature"}}]`},
+			Run: func(url string) (interface{}, error) {
+				return newTestProvider(url).FindPullRequests("wpgitupdates-plugin-akismet-")
+			},
+			Want: []interfaces.PullRequest{{Number: 7, State: interfaces.PullRequestOpen, Head: "wpgitupdates-plugin-akismet-4.0-4.1"}},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: "/api/v1/repos/owner/repo/pulls?state=open&per_page=50&limit=50&page=1", Body: ""},
+			},
+		},
+		{
+			Name:      "find by head",
+			Responses: map[string]string{"GET /api/v1/repos/owner/repo/pulls": `[{"number":7,"state":"closed","merged_at":"2021-01-01T00:00:00Z","head":{"ref":"wpgitupdates-a"}},{"number":8,"state":"closed","head":{"ref":"wpgitupdates-b"}}]`},
+			Run: func(url string) (interface{}, error) {
+				return newTestProvider(url).GetPullRequests("wpgitupdates-a")
+			},
+			Want: []interfaces.PullRequest{{Number: 7, State: interfaces.PullRequestMerged, Head: "wpgitupdates-a"}},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: "/api/v1/repos/owner/repo/pulls?state=all&per_page=50&limit=50&page=1", Body: ""},
+			},
+		},
+		{
+			Name: "update",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestProvider(url).UpdatePullRequest(pr)
+			},
+			Calls: []fakeapi.Call{
+				{Method: "PATCH", Uri: "/api/v1/repos/owner/repo/pulls/7", Body: `{"body":"Changes","title":"WIP: Update akismet"}`},
+			},
+		},
+		{
+			Name: "close",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestProvider(url).ClosePullRequest(pr, "Superseded by #9")
+			},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: "/api/v1/repos/owner/repo/issues/7/comments", Body: `{"body":"Superseded by #9"}`},
+				{Method: "PATCH", Uri: "/api/v1/repos/owner/repo/pulls/7", Body: `{"state":"closed"}`},
+			},
+		},
+		{
+			Name:      "labels",
+			Responses: map[string]string{"GET /api/v1/repos/owner/repo/labels": `[{"id":3,"name":"Dependencies"},{"id":4,"name":"bug"}]`},
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestProvider(url).AddLabels(pr, []string{"dependencies", "missing"})
+			},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: "/api/v1/repos/owner/repo/labels?limit=50", Body: ""},
+				{Method: "POST", Uri: "/api/v1/repos/owner/repo/issues/7/labels", Body: `{"labels":[3]}`},
+			},
+		},
+		{
+			Name:      "labels missing",
+			Responses: map[string]string{"GET /api/v1/repos/owner/repo/labels": `[]`},
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestProvider(url).AddLabels(pr, []string{"dependencies"})
+			},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: "/api/v1/repos/owner/repo/labels?limit=50", Body: ""},
+			},
+		},
+	}, "Authorization", "token secret")
+}
@@ -3,16 +3,19 @@ package github
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/wpgitupdater/wpgitupdater/internal/config"
 	"github.com/wpgitupdater/wpgitupdater/internal/constants"
 	"github.com/wpgitupdater/wpgitupdater/internal/git"
 	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
 	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"io"
 	"io/ioutil"
-	"log"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -20,7 +23,7 @@ import (
 	"time"
 )
 
-func CreateWorkflowTemplate() {
+func CreateWorkflowTemplate() error {
 	rand.Seed(time.Now().UnixNano())
 	hour := strconv.Itoa(rand.Intn(23))
 	minute := strconv.Itoa(rand.Intn(59))
@@ -42,69 +45,357 @@ jobs:
         WP_GIT_UPDATER_GIT_TOKEN: ${{ secrets.GITHUB_TOKEN }}`
 
 	if err := os.MkdirAll(filepath.Dir(constants.WorkflowFile), os.ModePerm); err != nil {
-		log.Fatal(err)
+		return err
 	}
 	if err := ioutil.WriteFile(constants.WorkflowFile, []byte(template), 644); err != nil {
-		log.Fatal(err)
+		return err
 	}
-	output := string(utils.RunCmd("chmod", "644", constants.WorkflowFile))
-	fmt.Println(output)
+	output, err := utils.RunCmd("chmod", "644", constants.WorkflowFile)
+	logger.Println(output)
+	return err
 }
 
-func CreatePullRequest(cnf *config.Config, r interfaces.Resource) error {
-	var base string
-	if cnf.Branch != "" {
-		base = cnf.Branch
-	} else {
-		base = git.CurrentBranch()
+// publicApiUrl is the API of github.com, releases are read from.
+const publicApiUrl = "https://api.github.com"
+
+// GitHub opens pull requests on an owner/repo repository.
+type GitHub struct {
+	Config     *config.Config
+	ApiUrl     string
+	Repository string
+}
+
+// NewProvider uses the API of github.com, or for GitHub Enterprise Server the
+// API under the web address, as provided to GitHub Actions when available.
+func NewProvider(cnf *config.Config, baseUrl string, repository string) GitHub {
+	apiUrl := strings.TrimRight(cnf.Provider.Url, "/")
+	if apiUrl == "" && baseUrl == "https://github.com" {
+		apiUrl = publicApiUrl
+	} else if apiUrl == "" && os.Getenv("GITHUB_API_URL") != "" && strings.EqualFold(os.Getenv("GITHUB_SERVER_URL"), baseUrl) {
+		apiUrl = strings.TrimRight(os.Getenv("GITHUB_API_URL"), "/")
+	} else if apiUrl == "" {
+		apiUrl = baseUrl + "/api/v3"
 	}
-	body := map[string]string{
-		"title": r.GetPRTitle(cnf),
-		"head":  r.GetBranchName(),
-		"base":  base,
-		"body": `**Update Generated By:** WordPress Git Updater V` + constants.Version + `
-**Build:** ` + constants.Build + `
-**Build Date:** ` + constants.BuildDate + `
+	return GitHub{Config: cnf, ApiUrl: apiUrl, Repository: repository}
+}
 
-**Homepage:** ` + r.GetHomePage() + `
-**Updated:** ` + r.GetLastUpdated() + `
+// pull is a pull request as returned by the GitHub and Gitea APIs.
+type pull struct {
+	Number   int     `json:"number"`
+	NodeId   string  `json:"node_id"`
+	HtmlUrl  string  `json:"html_url"`
+	State    string  `json:"state"`
+	MergedAt *string `json:"merged_at"`
+	Title    string  `json:"title"`
+	Body     string  `json:"body"`
+	Head     struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
 
-**Changelog:**
+func (p pull) toPullRequest() interfaces.PullRequest {
+	state := interfaces.PullRequestOpen
+	if p.MergedAt != nil {
+		state = interfaces.PullRequestMerged
+	} else if p.State == "closed" {
+		state = interfaces.PullRequestClosed
+	}
+	return interfaces.PullRequest{Number: p.Number, Url: p.HtmlUrl, State: state, Title: p.Title, Body: p.Body, Head: p.Head.Ref, Base: p.Base.Ref}
+}
 
-` + r.GetChangelog(),
+func (github GitHub) CreatePullRequest(pr interfaces.PullRequest) (interfaces.PullRequest, error) {
+	body := map[string]interface{}{
+		"title": pr.Title,
+		"head":  pr.Head,
+		"base":  pr.Base,
+		"body":  pr.Body,
+		"draft": pr.Draft,
 	}
 
-	data, err := json.Marshal(body)
+	responseBody, err := github.Request("POST", "/pulls", body)
 	if err != nil {
+		return pr, err
+	}
+
+	created := pull{}
+	err = json.Unmarshal(responseBody, &created)
+	return created.toPullRequest(), err
+}
+
+func (github GitHub) FindPullRequests(prefix string) ([]interfaces.PullRequest, error) {
+	return github.ListPullRequests("state=open", func(pr interfaces.PullRequest) bool {
+		return strings.HasPrefix(pr.Head, prefix)
+	})
+}
+
+func (github GitHub) GetPullRequests(head string) ([]interfaces.PullRequest, error) {
+	owner := strings.Split(github.Repository, "/")[0]
+	return github.ListPullRequests("state=all&head="+url.QueryEscape(owner+":"+head), func(pr interfaces.PullRequest) bool {
+		return pr.Head == head
+	})
+}
+
+// ListPullRequests pages through the pull requests matching query, returning
+// those accepted by filter.
+func (github GitHub) ListPullRequests(query string, filter func(pr interfaces.PullRequest) bool) ([]interfaces.PullRequest, error) {
+	found := []interfaces.PullRequest{}
+	for page := 1; ; page++ {
+		// per_page is read by GitHub and limit by Gitea
+		responseBody, err := github.Request("GET", "/pulls?"+query+"&per_page=50&limit=50&page="+strconv.Itoa(page), nil)
+		if err != nil {
+			return found, err
+		}
+		pulls := []pull{}
+		if err := json.Unmarshal(responseBody, &pulls); err != nil {
+			return found, err
+		}
+		for _, p := range pulls {
+			if pr := p.toPullRequest(); filter(pr) {
+				found = append(found, pr)
+			}
+		}
+		if len(pulls) < 50 {
+			return found, nil
+		}
+	}
+}
+
+func (github GitHub) UpdatePullRequest(pr interfaces.PullRequest) error {
+	_, err := github.Request("PATCH", "/pulls/"+strconv.Itoa(pr.Number), map[string]string{"title": pr.Title, "body": pr.Body})
+	return err
+}
+
+func (github GitHub) ClosePullRequest(pr interfaces.PullRequest, comment string) error {
+	if _, err := github.Request("POST", "/issues/"+strconv.Itoa(pr.Number)+"/comments", map[string]string{"body": comment}); err != nil {
 		return err
 	}
+	_, err := github.Request("PATCH", "/pulls/"+strconv.Itoa(pr.Number), map[string]string{"state": "closed"})
+	return err
+}
 
-	output := string(utils.RunCmd("git", "remote", "get-url", "origin"))
-	parts := strings.Split(strings.TrimSpace(output), "github.com/")
-	url := "https://api.github.com/repos/" + strings.Replace(parts[1], ".git", "", 1) + "/pulls"
-	client := &http.Client{}
+func (github GitHub) AddLabels(pr interfaces.PullRequest, labels []string) error {
+	_, err := github.Request("POST", "/issues/"+strconv.Itoa(pr.Number)+"/labels", map[string][]string{"labels": labels})
+	return err
+}
+
+func (github GitHub) RequestReviewers(pr interfaces.PullRequest, reviewers []string, teamReviewers []string) error {
+	body := map[string][]string{"reviewers": reviewers, "team_reviewers": teamReviewers}
+	_, err := github.Request("POST", "/pulls/"+strconv.Itoa(pr.Number)+"/requested_reviewers", body)
+	return err
+}
+
+func (github GitHub) AddAssignees(pr interfaces.PullRequest, assignees []string) error {
+	_, err := github.Request("POST", "/issues/"+strconv.Itoa(pr.Number)+"/assignees", map[string][]string{"assignees": assignees})
+	return err
+}
+
+func (github GitHub) SetMilestone(pr interfaces.PullRequest, milestone string) error {
+	number, err := github.FindMilestone(milestone)
+	if err != nil {
+		return err
+	}
+	_, err = github.Request("PATCH", "/issues/"+strconv.Itoa(pr.Number), map[string]int64{"milestone": number})
+	return err
+}
+
+// EnableAutoMerge uses the GraphQL API, as auto-merge cannot be enabled
+// through the REST API.
+func (github GitHub) EnableAutoMerge(pr interfaces.PullRequest, method string) error {
+	responseBody, err := github.Request("GET", "/pulls/"+strconv.Itoa(pr.Number), nil)
+	if err != nil {
+		return err
+	}
+	current := pull{}
+	if err := json.Unmarshal(responseBody, &current); err != nil {
+		return err
+	}
+
+	query := map[string]interface{}{
+		"query": `mutation($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) { clientMutationId }
+}`,
+		"variables": map[string]string{"id": current.NodeId, "method": strings.ToUpper(method)},
+	}
+	responseBody, err = apiRequest(github.Config.Token, "POST", github.getGraphQLUrl(), query)
+	if err != nil {
+		return err
+	}
+
+	// GraphQL reports errors with a successful status
+	result := struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return logger.Error(errors.New(result.Errors[0].Message))
+	}
+	return nil
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+// getGraphQLUrl returns the GraphQL endpoint alongside the REST API, which
+// GitHub Enterprise Server serves from /api/graphql.
+func (github GitHub) getGraphQLUrl() string {
+	if strings.HasSuffix(github.ApiUrl, "/api/v3") {
+		return strings.TrimSuffix(github.ApiUrl, "/v3") + "/graphql"
+	}
+	return github.ApiUrl + "/graphql"
+}
+
+// FindMilestone returns the number of the open milestone titled title, which
+// Gitea reports as the id.
+func (github GitHub) FindMilestone(title string) (int64, error) {
+	responseBody, err := github.Request("GET", "/milestones?state=open&per_page=100&limit=50", nil)
+	if err != nil {
+		return 0, err
+	}
+	milestones := []struct {
+		Id     int64  `json:"id"`
+		Number int64  `json:"number"`
+		Title  string `json:"title"`
+	}{}
+	if err := json.Unmarshal(responseBody, &milestones); err != nil {
+		return 0, err
+	}
+	for _, candidate := range milestones {
+		if strings.EqualFold(candidate.Title, title) {
+			if candidate.Number == 0 {
+				return candidate.Id, nil
+			}
+			return candidate.Number, nil
+		}
+	}
+	return 0, errors.New("milestone [" + title + "] does not exist")
+}
+
+// Request sends an authenticated request to the repositories API endpoint.
+func (github GitHub) Request(method string, path string, payload interface{}) ([]byte, error) {
+	return apiRequest(github.Config.Token, method, github.ApiUrl+"/repos/"+github.Repository+path, payload)
+}
+
+type ReleaseAsset struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+type Release struct {
+	TagName     string         `json:"tag_name"`
+	Draft       bool           `json:"draft"`
+	Prerelease  bool           `json:"prerelease"`
+	Body        string         `json:"body"`
+	HtmlUrl     string         `json:"html_url"`
+	PublishedAt string         `json:"published_at"`
+	ZipballUrl  string         `json:"zipball_url"`
+	Assets      []ReleaseAsset `json:"assets"`
+}
+
+// GetReleases lists the published releases of an owner/repo repository.
+func GetReleases(cnf *config.Config, repository string) ([]Release, error) {
+	releases := []Release{}
+	responseBody, err := apiRequest(getReleasesToken(cnf), "GET", publicApiUrl+"/repos/"+repository+"/releases", nil)
+	if err != nil {
+		return releases, err
+	}
+	err = json.Unmarshal(responseBody, &releases)
+	return releases, err
+}
+
+// Download saves a release asset or zipball, authenticating so assets of
+// private repositories can be fetched.
+func Download(cnf *config.Config, url string, location string) error {
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Add("Authorization", "token "+cnf.Token)
+	if token := getReleasesToken(cnf); token != "" && strings.HasPrefix(url, publicApiUrl+"/") {
+		req.Header.Add("Authorization", "token "+token)
+	}
+	req.Header.Add("User-Agent", constants.UserAgent)
+	req.Header.Set("Accept", "application/octet-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return logger.Error(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return logger.Error(fmt.Errorf("%s: unexpected status %d", url, resp.StatusCode))
+	}
+
+	out, err := os.Create(location)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
+
+// getReleasesToken returns the token releases are read from api.github.com
+// with. The git token is only sent when the site repository is hosted on
+// github.com, otherwise the optional WP_GIT_UPDATER_GITHUB_TOKEN is used.
+func getReleasesToken(cnf *config.Config) string {
+	if cnf.GitHubToken != "" {
+		return cnf.GitHubToken
+	}
+	if cnf.Provider.Type != "" && cnf.Provider.Type != "github" {
+		return ""
+	}
+	remote, err := git.ResolveRemote(cnf)
+	if err != nil || NewProvider(cnf, remote.BaseUrl, remote.Path).ApiUrl != publicApiUrl {
+		return ""
+	}
+	return cnf.Token
+}
+
+// apiRequest sends an API request, authenticated when a token is given,
+// returning the response body or an error for unsuccessful status codes.
+func apiRequest(token string, method string, url string, payload interface{}) ([]byte, error) {
+	var data []byte
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		data = encoded
+	}
+
+	client := &http.Client{}
+
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		req.Header.Add("Authorization", "token "+token)
+	}
 	req.Header.Add("User-Agent", constants.UserAgent)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, logger.Error(err)
 	}
 
 	defer resp.Body.Close()
 	responseBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Println(string(responseBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseBody, logger.Error(errors.New(string(responseBody)))
+	}
 
-	return nil
+	return responseBody, nil
 }
@@ -0,0 +1,93 @@
+package github
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/fakeapi"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestProvider(url string) GitHub {
+	return NewProvider(&config.Config{Token: "secret", Provider: config.ProviderConfig{Url: url}}, "https://github.com", "owner/repo")
+}
+
+func TestProvider(t *testing.T) {
+	pr := interfaces.PullRequest{Number: 7, Title: "Update akismet", Head: "wpgitupdates-plugin-akismet-4.0-4.1", Base: "main", Body: "Changes", Draft: true}
+	fakeapi.Run(t, []fakeapi.Case{
+		{
+			Name:      "create",
+			Responses: map[string]string{"POST /repos/owner/repo/pulls": `{"number":7,"html_url":"https://github.com/owner/repo/pull/7","state":"open","title":"Update akismet","head":{"ref":"wpgitupdates-plugin-akismet-4.0-4.1"},"base":{"ref":"main"}}`},
+			Run: func(url string) (interface{}, error) {
+				return newTestProvider(url).CreatePullRequest(pr)
+			},
+			Want: interfaces.PullRequest{Number: 7, Url: "https://github.com/owner/repo/pull/7", State: interfaces.PullRequestOpen, Title: "Update akismet", Head: "wpgitupdates-plugin-akismet-4.0-4.1", Base: "main"},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: "/repos/owner/repo/pulls", Body: `{"base":"main","body":"Changes","draft":true,"head":"wpgitupdates-plugin-akismet-4.0-4.1","title":"Update akismet"}`},
+			},
+		},
+		{
+			Name:      "find",
+			Responses: map[string]string{"GET /repos/owner/repo/pulls": `[{"number":7,"state":"open","head":{"ref":"wpgitupdates-plugin-akismet-4.0-4.1"}},{"number":8,"state":"open","head":{"ref":"feature"}}]`},
+			Run: func(url string) (interface{}, error) {
+				return newTestProvider(url).FindPullRequests("wpgitupdates-plugin-akismet-")
+			},
+			Want: []interfaces.PullRequest{{Number: 7, State: interfaces.PullRequestOpen, Head: "wpgitupdates-plugin-akismet-4.0-4.1"}},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: "/repos/owner/repo/pulls?state=open&per_page=50&limit=50&page=1", Body: ""},
+			},
+		},
+		{
+			Name:      "find merged and closed",
+			Responses: map[string]string{"GET /repos/owner/repo/pulls": `[{"number":7,"state":"closed","merged_at":"2021-01-01T00:00:00Z","head":{"ref":"wpgitupdates-a"}},{"number":8,"state":"closed","head":{"ref":"wpgitupdates-a"}}]`},
+			Run: func(url string) (interface{}, error) {
+				return newTestProvider(url).GetPullRequests("wpgitupdates-a")
+			},
+			Want: []interfaces.PullRequest{{Number: 7, State: interfaces.PullRequestMerged, Head: "wpgitupdates-a"}, {Number: 8, State: interfaces.PullRequestClosed, Head: "wpgitupdates-a"}},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: "/repos/owner/repo/pulls?state=all&head=owner%3Awpgitupdates-a&per_page=50&limit=50&page=1", Body: ""},
+			},
+		},
+		{
+			Name: "update",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestProvider(url).UpdatePullRequest(pr)
+			},
+			Calls: []fakeapi.Call{
+				{Method: "PATCH", Uri: "/repos/owner/repo/pulls/7", Body: `{"body":"Changes","title":"Update akismet"}`},
+			},
+		},
+		{
+			Name: "close",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestProvider(url).ClosePullRequest(pr, "Superseded by #9")
+			},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: "/repos/owner/repo/issues/7/comments", Body: `{"body":"Superseded by #9"}`},
+				{Method: "PATCH", Uri: "/repos/owner/repo/pulls/7", Body: `{"state":"closed"}`},
+			},
+		},
+		{
+			Name: "labels",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestProvider(url).AddLabels(pr, []string{"dependencies", "wordpress"})
+			},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: "/repos/owner/repo/issues/7/labels", Body: `{"labels":["dependencies","wordpress"]}`},
+			},
+		},
+	}, "Authorization", "token secret")
+}
+
+func TestProviderError(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Validation Failed"}`, http.StatusUnprocessableEntity)
+	}))
+	defer api.Close()
+
+	if _, err := newTestProvider(api.URL).CreatePullRequest(interfaces.PullRequest{Title: "Update"}); err == nil || !strings.Contains(err.Error(), "Validation Failed") {
+		t.Errorf("got error %v, want Validation Failed", err)
+	}
+}
@@ -0,0 +1,250 @@
+package gitlab
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/constants"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GitLab opens merge requests on a project through the v4 API, of gitlab.com
+// or a self-hosted instance.
+type GitLab struct {
+	Config  *config.Config
+	ApiUrl  string
+	Project string
+}
+
+func NewProvider(cnf *config.Config, baseUrl string, project string) GitLab {
+	apiUrl := strings.TrimRight(cnf.Provider.Url, "/")
+	if apiUrl == "" {
+		apiUrl = baseUrl + "/api/v4"
+	}
+	return GitLab{Config: cnf, ApiUrl: apiUrl, Project: project}
+}
+
+type mergeRequest struct {
+	Iid          int    `json:"iid"`
+	WebUrl       string `json:"web_url"`
+	State        string `json:"state"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+}
+
+func (mr mergeRequest) toPullRequest() interfaces.PullRequest {
+	state := interfaces.PullRequestClosed
+	if mr.State == "opened" {
+		state = interfaces.PullRequestOpen
+	} else if mr.State == "merged" {
+		state = interfaces.PullRequestMerged
+	}
+	return interfaces.PullRequest{Number: mr.Iid, Url: mr.WebUrl, State: state, Title: mr.Title, Body: mr.Description, Head: mr.SourceBranch, Base: mr.TargetBranch}
+}
+
+func (gitlab GitLab) CreatePullRequest(pr interfaces.PullRequest) (interfaces.PullRequest, error) {
+	body := map[string]interface{}{
+		"title":                getTitle(pr),
+		"source_branch":        pr.Head,
+		"target_branch":        pr.Base,
+		"description":          pr.Body,
+		"remove_source_branch": true,
+	}
+
+	responseBody, err := gitlab.request("POST", "/merge_requests", body)
+	if err != nil {
+		return pr, err
+	}
+
+	created := mergeRequest{}
+	err = json.Unmarshal(responseBody, &created)
+	return created.toPullRequest(), err
+}
+
+func (gitlab GitLab) FindPullRequests(prefix string) ([]interfaces.PullRequest, error) {
+	return gitlab.listPullRequests("state=opened", func(pr interfaces.PullRequest) bool {
+		return strings.HasPrefix(pr.Head, prefix)
+	})
+}
+
+func (gitlab GitLab) GetPullRequests(head string) ([]interfaces.PullRequest, error) {
+	return gitlab.listPullRequests("state=all&source_branch="+url.QueryEscape(head), func(pr interfaces.PullRequest) bool {
+		return pr.Head == head
+	})
+}
+
+func (gitlab GitLab) listPullRequests(query string, filter func(pr interfaces.PullRequest) bool) ([]interfaces.PullRequest, error) {
+	found := []interfaces.PullRequest{}
+	for page := 1; ; page++ {
+		responseBody, err := gitlab.request("GET", "/merge_requests?"+query+"&per_page=50&page="+strconv.Itoa(page), nil)
+		if err != nil {
+			return found, err
+		}
+		requests := []mergeRequest{}
+		if err := json.Unmarshal(responseBody, &requests); err != nil {
+			return found, err
+		}
+		for _, mr := range requests {
+			if pr := mr.toPullRequest(); filter(pr) {
+				found = append(found, pr)
+			}
+		}
+		if len(requests) < 50 {
+			return found, nil
+		}
+	}
+}
+
+func (gitlab GitLab) UpdatePullRequest(pr interfaces.PullRequest) error {
+	_, err := gitlab.request("PUT", "/merge_requests/"+strconv.Itoa(pr.Number), map[string]string{"title": getTitle(pr), "description": pr.Body})
+	return err
+}
+
+func (gitlab GitLab) ClosePullRequest(pr interfaces.PullRequest, comment string) error {
+	if _, err := gitlab.request("POST", "/merge_requests/"+strconv.Itoa(pr.Number)+"/notes", map[string]string{"body": comment}); err != nil {
+		return err
+	}
+	_, err := gitlab.request("PUT", "/merge_requests/"+strconv.Itoa(pr.Number), map[string]string{"state_event": "close"})
+	return err
+}
+
+func (gitlab GitLab) AddLabels(pr interfaces.PullRequest, labels []string) error {
+	_, err := gitlab.request("PUT", "/merge_requests/"+strconv.Itoa(pr.Number), map[string]string{"add_labels": strings.Join(labels, ",")})
+	return err
+}
+
+func (gitlab GitLab) RequestReviewers(pr interfaces.PullRequest, reviewers []string, teamReviewers []string) error {
+	if len(teamReviewers) > 0 {
+		logger.Printf("GitLab does not support team reviewers, skipping [%s]\n", strings.Join(teamReviewers, ", "))
+	}
+	if len(reviewers) == 0 {
+		return nil
+	}
+	ids, err := gitlab.getUserIds(reviewers)
+	if err != nil {
+		return err
+	}
+	_, err = gitlab.request("PUT", "/merge_requests/"+strconv.Itoa(pr.Number), map[string][]int64{"reviewer_ids": ids})
+	return err
+}
+
+func (gitlab GitLab) AddAssignees(pr interfaces.PullRequest, assignees []string) error {
+	ids, err := gitlab.getUserIds(assignees)
+	if err != nil {
+		return err
+	}
+	_, err = gitlab.request("PUT", "/merge_requests/"+strconv.Itoa(pr.Number), map[string][]int64{"assignee_ids": ids})
+	return err
+}
+
+func (gitlab GitLab) SetMilestone(pr interfaces.PullRequest, milestone string) error {
+	responseBody, err := gitlab.request("GET", "/milestones?state=active&title="+url.QueryEscape(milestone), nil)
+	if err != nil {
+		return err
+	}
+	milestones := []struct {
+		Id int64 `json:"id"`
+	}{}
+	if err := json.Unmarshal(responseBody, &milestones); err != nil {
+		return err
+	}
+	if len(milestones) == 0 {
+		return errors.New("milestone [" + milestone + "] does not exist")
+	}
+	_, err = gitlab.request("PUT", "/merge_requests/"+strconv.Itoa(pr.Number), map[string]int64{"milestone_id": milestones[0].Id})
+	return err
+}
+
+// EnableAutoMerge merges once the pipeline succeeds, rebasing follows the
+// merge method of the project.
+func (gitlab GitLab) EnableAutoMerge(pr interfaces.PullRequest, method string) error {
+	body := map[string]bool{"merge_when_pipeline_succeeds": true, "squash": method == "squash"}
+	_, err := gitlab.request("PUT", "/merge_requests/"+strconv.Itoa(pr.Number)+"/merge", body)
+	return err
+}
+
+// getTitle marks drafts with the Draft: title prefix.
+func getTitle(pr interfaces.PullRequest) string {
+	if pr.Draft {
+		return "Draft: " + pr.Title
+	}
+	return pr.Title
+}
+
+// getUserIds looks up the ids of project members by username.
+func (gitlab GitLab) getUserIds(usernames []string) ([]int64, error) {
+	ids := []int64{}
+	for _, username := range usernames {
+		responseBody, err := gitlab.request("GET", "/members/all?query="+url.QueryEscape(username), nil)
+		if err != nil {
+			return ids, err
+		}
+		members := []struct {
+			Id       int64  `json:"id"`
+			Username string `json:"username"`
+		}{}
+		if err := json.Unmarshal(responseBody, &members); err != nil {
+			return ids, err
+		}
+		found := false
+		for _, member := range members {
+			if strings.EqualFold(member.Username, username) {
+				ids = append(ids, member.Id)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ids, errors.New("user [" + username + "] is not a project member")
+		}
+	}
+	return ids, nil
+}
+
+// request sends an authenticated request to the projects API endpoint, the
+// project path is encoded as GitLab expects in place of a numeric id.
+func (gitlab GitLab) request(method string, path string, payload interface{}) ([]byte, error) {
+	var data []byte
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		data = encoded
+	}
+
+	req, err := http.NewRequest(method, gitlab.ApiUrl+"/projects/"+url.PathEscape(gitlab.Project)+path, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("PRIVATE-TOKEN", gitlab.Config.Token)
+	req.Header.Add("User-Agent", constants.UserAgent)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, logger.Error(err)
+	}
+
+	defer resp.Body.Close()
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseBody, logger.Error(errors.New(string(responseBody)))
+	}
+
+	return responseBody, nil
+}
@@ -0,0 +1,79 @@
+package gitlab
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/fakeapi"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"testing"
+)
+
+func newTestProvider(url string) GitLab {
+	return NewProvider(&config.Config{Token: "secret"}, url, "group/sub/repo")
+}
+
+func TestProvider(t *testing.T) {
+	pr := interfaces.PullRequest{Number: 7, Title: "Update akismet", Head: "wpgitupdates-plugin-akismet-4.0-4.1", Base: "main", Body: "Changes", Draft: true}
+	fakeapi.Run(t, []fakeapi.Case{
+		{
+			Name:      "create",
+			Responses: map[string]string{"POST /api/v4/projects/group/sub/repo/merge_requests": `{"iid":7,"web_url":"https://gitlab.com/group/sub/repo/-/merge_requests/7","state":"opened","title":"Draft: Update akismet","source_branch":"wpgitupdates-plugin-akismet-4.0-4.1","target_branch":"main"}`},
+			Run: func(url string) (interface{}, error) {
+				return newTestProvider(url).CreatePullRequest(pr)
+			},
+			Want: interfaces.PullRequest{Number: 7, Url: "https://gitlab.com/group/sub/repo/-/merge_requests/7", State: interfaces.PullRequestOpen, Title: "Draft: Update akismet", Head: "wpgitupdates-plugin-akismet-4.0-4.1", Base: "main"},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: "/api/v4/projects/group%2Fsub%2Frepo/merge_requests", Body: `{"description":"Changes","remove_source_branch":true,"source_branch":"wpgitupdates-plugin-akismet-4.0-4.1","target_branch":"main","title":"Draft: Update akismet"}`},
+			},
+		},
+		{
+			Name:      "find",
+			Responses: map[string]string{"GET /api/v4/projects/group/sub/repo/merge_requests": `[{"iid":7,"state":"opened","source_branch":"wpgitupdates-plugin-akismet-4.0-4.1"},{"iid":8,"state":"opened","source_branch":"feature"}]`},
+			Run: func(url string) (interface{}, error) {
+				return newTestProvider(url).FindPullRequests("wpgitupdates-plugin-akismet-")
+			},
+			Want: []interfaces.PullRequest{{Number: 7, State: interfaces.PullRequestOpen, Head: "wpgitupdates-plugin-akismet-4.0-4.1"}},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: "/api/v4/projects/group%2Fsub%2Frepo/merge_requests?state=opened&per_page=50&page=1", Body: ""},
+			},
+		},
+		{
+			Name:      "find by head",
+			Responses: map[string]string{"GET /api/v4/projects/group/sub/repo/merge_requests": `[{"iid":7,"state":"merged","source_branch":"wpgitupdates-a"},{"iid":8,"state":"closed","source_branch":"wpgitupdates-a"}]`},
+			Run: func(url string) (interface{}, error) {
+				return newTestProvider(url).GetPullRequests("wpgitupdates-a")
+			},
+			Want: []interfaces.PullRequest{{Number: 7, State: interfaces.PullRequestMerged, Head: "wpgitupdates-a"}, {Number: 8, State: interfaces.PullRequestClosed, Head: "wpgitupdates-a"}},
+			Calls: []fakeapi.Call{
+				{Method: "GET", Uri: "/api/v4/projects/group%2Fsub%2Frepo/merge_requests?state=all&source_branch=wpgitupdates-a&per_page=50&page=1", Body: ""},
+			},
+		},
+		{
+			Name: "update",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestProvider(url).UpdatePullRequest(pr)
+			},
+			Calls: []fakeapi.Call{
+				{Method: "PUT", Uri: "/api/v4/projects/group%2Fsub%2Frepo/merge_requests/7", Body: `{"description":"Changes","title":"Draft: Update akismet"}`},
+			},
+		},
+		{
+			Name: "close",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestProvider(url).ClosePullRequest(pr, "Superseded by !9")
+			},
+			Calls: []fakeapi.Call{
+				{Method: "POST", Uri: "/api/v4/projects/group%2Fsub%2Frepo/merge_requests/7/notes", Body: `{"body":"Superseded by !9"}`},
+				{Method: "PUT", Uri: "/api/v4/projects/group%2Fsub%2Frepo/merge_requests/7", Body: `{"state_event":"close"}`},
+			},
+		},
+		{
+			Name: "labels",
+			Run: func(url string) (interface{}, error) {
+				return nil, newTestProvider(url).AddLabels(pr, []string{"dependencies", "wordpress"})
+			},
+			Calls: []fakeapi.Call{
+				{Method: "PUT", Uri: "/api/v4/projects/group%2Fsub%2Frepo/merge_requests/7", Body: `{"add_labels":"dependencies,wordpress"}`},
+			},
+		},
+	}, "PRIVATE-TOKEN", "secret")
+}
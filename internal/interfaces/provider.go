@@ -0,0 +1,49 @@
+package interfaces
+
+// Provider opens change requests, such as GitHub pull requests or GitLab
+// merge requests, on the host of the origin repository.
+type Provider interface {
+	CreatePullRequest(pr PullRequest) (PullRequest, error)
+	// FindPullRequests returns the open pull requests whose head branch
+	// starts with prefix.
+	FindPullRequests(prefix string) ([]PullRequest, error)
+	// GetPullRequests returns the pull requests of any state opened from
+	// the head branch.
+	GetPullRequests(head string) ([]PullRequest, error)
+	UpdatePullRequest(pr PullRequest) error
+	ClosePullRequest(pr PullRequest, comment string) error
+	AddLabels(pr PullRequest, labels []string) error
+	RequestReviewers(pr PullRequest, reviewers []string, teamReviewers []string) error
+	AddAssignees(pr PullRequest, assignees []string) error
+	// SetMilestone adds the pull request to the open milestone titled
+	// milestone.
+	SetMilestone(pr PullRequest, milestone string) error
+	// EnableAutoMerge merges the pull request with method, merge, squash or
+	// rebase, once its required checks pass.
+	EnableAutoMerge(pr PullRequest, method string) error
+}
+
+const PullRequestOpen = "open"
+const PullRequestClosed = "closed"
+const PullRequestMerged = "merged"
+
+// PullRequest is a change request to open, or one returned by a provider
+// with its Number, Url and State set.
+type PullRequest struct {
+	Number int
+	Url    string
+	State  string
+	Title  string
+	Head   string
+	Base   string
+	Body   string
+	Labels []string
+	Draft  bool
+	// Reviewers, TeamReviewers, Assignees, Milestone and AutoMerge are
+	// applied once the pull request has been opened.
+	Reviewers     []string
+	TeamReviewers []string
+	Assignees     []string
+	Milestone     string
+	AutoMerge     string
+}
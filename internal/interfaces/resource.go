@@ -1,11 +1,55 @@
 package interfaces
 
-import "github.com/wpgitupdater/wpgitupdater/internal/config"
-
 type Resource interface {
-	GetBranchName() string
-	GetPRTitle(*config.Config) string
+	GetKind() string
+	GetSlug() string
+	GetName() string
+	GetInstalledVersion() string
+	GetAvailableVersion() string
+	GetDownloadUrl() string
+	GetInstallDir() string
 	GetHomePage() string
 	GetLastUpdated() string
+	GetReleaseDate() string
 	GetChangelog() string
+	GetRequirements() Requirements
+}
+
+// Requirements of the available version, empty when not declared. Unknown is
+// set when they are not published for the available version at all.
+type Requirements struct {
+	WordPress string
+	PHP       string
+	Tested    string
+	Unknown   bool
+}
+
+// Installer is implemented by resources that cannot simply be swapped with
+// the staged copy extracted from the downloaded archive.
+type Installer interface {
+	Install(staged string) error
+}
+
+// Validator is implemented by resources that can confirm a staged copy is
+// the expected version before it replaces the installed one.
+type Validator interface {
+	Validate(staged string) error
+}
+
+// Downloader is implemented by resources whose download links need more than
+// a plain GET request, such as authenticated release assets.
+type Downloader interface {
+	Download(url string, location string) error
+}
+
+// Verifier is implemented by resources with published file checksums, nil
+// checksums meaning none are published for the available version.
+type Verifier interface {
+	GetChecksums() (map[string][]string, error)
+}
+
+// Preserver is implemented by resources whose update leaves some paths of the
+// install directory in place, which are not verified against checksums.
+type Preserver interface {
+	GetPreserved() []string
 }
@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const mask = "***"
+
+// minSecretLength skips values too short to be credentials, which would mask
+// the same characters within every other word.
+const minSecretLength = 6
+
+var mutex sync.RWMutex
+var secrets []string
+
+var credentialsR = regexp.MustCompile(`(?i)([a-z][a-z0-9+.\-]*://[^:@/\s]+:)[^@/\s]+@`)
+var userinfoR = regexp.MustCompile(`(?i)([a-z][a-z0-9+.\-]*://)[^:@/\s]+@`)
+var secretParamR = regexp.MustCompile(`(?i)\b([a-z_\-]*(?:token|key|secret|password|license|signature)=)[^&#\s"']+`)
+var headerR = regexp.MustCompile(`(?i)\b((?:authorization|proxy-authorization|private-token|x-api-key):\s*(?:(?:basic|bearer|token)\s+)?)[^\s"']+`)
+
+// AddSecret registers a value that must never be printed, such as an API
+// token, so it is masked in everything passed through the logger. Values
+// shorter than minSecretLength are ignored.
+func AddSecret(secret string) {
+	secret = strings.TrimSpace(secret)
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, existing := range secrets {
+		if existing == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+	// Replace the longest secrets first, in case one contains another
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+}
+
+// Redact masks registered secrets along with credentials, tokens and keys
+// found in urls and authorization headers within text. Userinfo without a
+// password is masked whole, as it is usually a token.
+func Redact(text string) string {
+	mutex.RLock()
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, mask)
+	}
+	mutex.RUnlock()
+
+	text = credentialsR.ReplaceAllString(text, "${1}"+mask+"@")
+	text = userinfoR.ReplaceAllString(text, "${1}"+mask+"@")
+	text = headerR.ReplaceAllString(text, "${1}"+mask)
+	return secretParamR.ReplaceAllString(text, "${1}"+mask)
+}
+
+func Printf(format string, a ...interface{}) {
+	fmt.Print(Redact(fmt.Sprintf(format, a...)))
+}
+
+func Println(a ...interface{}) {
+	fmt.Print(Redact(fmt.Sprintln(a...)))
+}
+
+type redacted struct {
+	err error
+}
+
+func (err redacted) Error() string {
+	return Redact(err.err.Error())
+}
+
+func (err redacted) Unwrap() error {
+	return err.err
+}
+
+// Error wraps err so its message is redacted wherever it is printed.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	return redacted{err}
+}
@@ -0,0 +1,160 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const Patch = "patch"
+const Minor = "minor"
+const Major = "major"
+
+var constraintR = regexp.MustCompile(`(~|\^|>=|<=|!=|==|=|>|<)?\s*([0-9][0-9A-Za-z.\-+*]*)`)
+
+type condition struct {
+	operator string
+	version  string
+}
+
+// Allows reports whether moving from installed to candidate is permitted by
+// the policy. A policy is one of patch, minor or major, or a version
+// constraint such as "~5.2", "^5.2", "5.2.*", "<6.0" or ">=5.0, <6.0".
+func Allows(policy string, installed string, candidate string) (bool, error) {
+	policy = strings.TrimSpace(policy)
+	switch policy {
+	case "", Major, "*":
+		return true, nil
+	case Minor:
+		return utils.VersionBump(installed, candidate) != Major, nil
+	case Patch:
+		return utils.VersionBump(installed, candidate) == Patch, nil
+	}
+
+	conditions, err := parse(policy)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range conditions {
+		if !utils.VersionCompare(candidate, c.version, c.operator) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Select returns the highest candidate newer than installed that the policy
+// allows, or an empty string when there is none. Pre-release candidates are
+// only considered when the policy names a pre-release version.
+func Select(policy string, installed string, candidates []string) (string, error) {
+	preRelease := NamesPreRelease(policy)
+	selected := ""
+	for _, candidate := range candidates {
+		if !utils.VersionCompare(installed, candidate, "<") {
+			continue
+		}
+		if !preRelease && utils.IsPreRelease(candidate) {
+			continue
+		}
+		if selected != "" && !utils.VersionCompare(selected, candidate, "<") {
+			continue
+		}
+		allowed, err := Allows(policy, installed, candidate)
+		if err != nil {
+			return "", err
+		}
+		if allowed {
+			selected = candidate
+		}
+	}
+	return selected, nil
+}
+
+// NamesPreRelease reports whether a version constraint policy names a
+// pre-release version, such as ">=8.0.0-beta.1".
+func NamesPreRelease(policy string) bool {
+	switch strings.TrimSpace(policy) {
+	case "", Major, Minor, Patch, "*":
+		return false
+	}
+	for _, match := range constraintR.FindAllStringSubmatch(policy, -1) {
+		if utils.IsPreRelease(match[2]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse validates a policy, returning an error unless it is one of patch,
+// minor or major or a valid version constraint.
+func Parse(policy string) error {
+	_, err := Allows(policy, "0", "0")
+	return err
+}
+
+func parse(policy string) ([]condition, error) {
+	conditions := []condition{}
+	matches := constraintR.FindAllStringSubmatch(policy, -1)
+	if len(matches) == 0 || strings.Trim(constraintR.ReplaceAllString(policy, ""), " ,") != "" {
+		return conditions, fmt.Errorf("invalid update policy [%s]", policy)
+	}
+
+	for _, match := range matches {
+		operator, version := match[1], match[2]
+		switch {
+		case operator == "~":
+			conditions = append(conditions, condition{">=", version}, condition{"<", increment(version, tildeSegment(version))})
+		case operator == "^":
+			conditions = append(conditions, condition{">=", version}, condition{"<", increment(version, caretSegment(version))})
+		case strings.HasSuffix(version, ".*") || strings.HasSuffix(version, ".x"):
+			base := version[:len(version)-2]
+			conditions = append(conditions, condition{">=", base}, condition{"<", increment(base, len(segments(base))-1)})
+		case operator == "":
+			conditions = append(conditions, condition{"==", version})
+		default:
+			conditions = append(conditions, condition{operator, version})
+		}
+	}
+	return conditions, nil
+}
+
+func segments(version string) []string {
+	return strings.Split(version, ".")
+}
+
+// tildeSegment allows patch level changes when a minor version is given and
+// minor level changes otherwise, matching npm.
+func tildeSegment(version string) int {
+	if len(segments(version)) > 1 {
+		return 1
+	}
+	return 0
+}
+
+// caretSegment finds the left most non zero segment, which ^ locks.
+func caretSegment(version string) int {
+	parts := segments(version)
+	for i, part := range parts {
+		if part != "0" {
+			return i
+		}
+	}
+	return len(parts) - 1
+}
+
+// increment bumps the segment at index and drops the segments after it, so
+// increment("5.2.3", 1) is "5.3".
+func increment(version string, index int) string {
+	parts := segments(version)
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(parts) {
+		index = len(parts) - 1
+	}
+	number, _ := strconv.Atoi(parts[index])
+	parts[index] = strconv.Itoa(number + 1)
+	return strings.Join(parts[:index+1], ".")
+}
@@ -0,0 +1,99 @@
+package policy
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		policy string
+		valid  bool
+	}{
+		{"", true},
+		{"patch", true},
+		{"minor", true},
+		{"major", true},
+		{"*", true},
+		{"~5.2", true},
+		{"^5.2.1", true},
+		{"5.2.*", true},
+		{"5.x", true},
+		{"<6.0", true},
+		{">=5.0, <6.0", true},
+		{">=8.0.0-beta.1", true},
+		{"5.2.1", true},
+		{"latest", false},
+		{"foo 5.0", false},
+		{">=5.0 or <6.0", false},
+		{"<", false},
+	}
+
+	for _, test := range tests {
+		err := Parse(test.policy)
+		if test.valid && err != nil {
+			t.Errorf("Parse(%q) returned %s, want valid", test.policy, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Parse(%q) is valid, want an error", test.policy)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	candidates := []string{"4.9.1", "5.0", "5.1.2", "5.2", "5.2.3", "5.3", "6.0", "6.1-beta1", "6.1RC2"}
+	tests := []struct {
+		policy    string
+		installed string
+		want      string
+	}{
+		{"", "5.1", "6.0"},
+		{"major", "5.1", "6.0"},
+		{"minor", "5.1", "5.3"},
+		{"patch", "5.1", "5.1.2"},
+		{"patch", "5.3", ""},
+		{"~5.2", "5.1", "5.2.3"},
+		{"^5.2", "5.1", "5.3"},
+		{"5.2.*", "5.1", "5.2.3"},
+		{"<6.0", "5.1", "5.3"},
+		{">=5.0, <5.3", "4.9.1", "5.2.3"},
+		{"5.2", "5.1", "5.2"},
+		{"major", "6.0", ""},
+		{">=6.1-beta1", "6.0", "6.1RC2"},
+	}
+
+	for _, test := range tests {
+		got, err := Select(test.policy, test.installed, candidates)
+		if err != nil {
+			t.Errorf("Select(%q, %q) returned %s", test.policy, test.installed, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Select(%q, %q) = %q, want %q", test.policy, test.installed, got, test.want)
+		}
+	}
+
+	if _, err := Select("latest", "5.1", candidates); err == nil {
+		t.Error("Select with an invalid policy returned no error")
+	}
+}
+
+func TestNamesPreRelease(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   bool
+	}{
+		{"", false},
+		{"patch", false},
+		{"minor", false},
+		{"major", false},
+		{"*", false},
+		{"^5.2", false},
+		{">=5.0, <6.0", false},
+		{">=8.0.0-beta.1", true},
+		{"~6.1RC1", true},
+	}
+
+	for _, test := range tests {
+		if got := NamesPreRelease(test.policy); got != test.want {
+			t.Errorf("NamesPreRelease(%q) = %t, want %t", test.policy, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/bitbucket"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/git"
+	"github.com/wpgitupdater/wpgitupdater/internal/gitea"
+	"github.com/wpgitupdater/wpgitupdater/internal/github"
+	"github.com/wpgitupdater/wpgitupdater/internal/gitlab"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const GitHubProvider = "github"
+const GitLabProvider = "gitlab"
+const BitbucketProvider = "bitbucket"
+const BitbucketServerProvider = "bitbucket-server"
+const GiteaProvider = "gitea"
+const ForgejoProvider = "forgejo"
+
+// Get returns the provider configured for the repository, or the provider
+// detected from the host of the origin remote.
+func Get(cnf *config.Config) (interfaces.Provider, error) {
+	remote, err := git.ResolveRemote(cnf)
+	if err != nil {
+		return nil, err
+	}
+
+	providerType := cnf.Provider.Type
+	if providerType == "" {
+		providerType = Detect(remote.Host)
+	}
+
+	switch providerType {
+	case GitHubProvider:
+		return github.NewProvider(cnf, remote.BaseUrl, remote.Path), nil
+	case GitLabProvider:
+		return gitlab.NewProvider(cnf, remote.BaseUrl, remote.Path), nil
+	case BitbucketProvider:
+		return bitbucket.NewCloudProvider(cnf, remote.Path), nil
+	case BitbucketServerProvider:
+		return bitbucket.NewServerProvider(cnf, remote.BaseUrl, remote.Path)
+	case GiteaProvider, ForgejoProvider:
+		return gitea.NewProvider(cnf, remote.BaseUrl, remote.Path), nil
+	default:
+		return nil, fmt.Errorf("unable to detect the provider of [%s], configure a provider type", remote.Host)
+	}
+}
+
+// Detect guesses the provider type from a git host name, recognising GitHub
+// Enterprise Server hosts when running within their GitHub Actions.
+func Detect(host string) string {
+	switch {
+	case host == "github.com" || host == getActionsHost():
+		return GitHubProvider
+	case host == "gitlab.com" || strings.HasPrefix(host, "gitlab."):
+		return GitLabProvider
+	case host == "bitbucket.org":
+		return BitbucketProvider
+	case strings.HasPrefix(host, "bitbucket."):
+		return BitbucketServerProvider
+	case host == "codeberg.org" || strings.HasPrefix(host, "gitea.") || strings.HasPrefix(host, "forgejo."):
+		return GiteaProvider
+	default:
+		return ""
+	}
+}
+
+func getActionsHost() string {
+	server, err := url.Parse(os.Getenv("GITHUB_SERVER_URL"))
+	if err != nil || server.Hostname() == "" {
+		return "-"
+	}
+	return strings.ToLower(server.Hostname())
+}
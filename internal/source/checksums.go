@@ -0,0 +1,100 @@
+package source
+
+import (
+	"encoding/json"
+	"github.com/wpgitupdater/wpgitupdater/internal/constants"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"strings"
+)
+
+// Verifier is implemented by sources publishing checksums for every file of a
+// version, keyed by the path relative to the resource root.
+type Verifier interface {
+	GetChecksums(slug string, version string) (map[string][]string, error)
+}
+
+// digests decodes checksum fields the API reports as either a single digest
+// or a list of accepted digests.
+type digests []string
+
+func (d *digests) UnmarshalJSON(data []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		list := []string{}
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		*d = list
+		return nil
+	}
+	single := ""
+	if err := json.Unmarshal(data, &single); err != nil {
+		return err
+	}
+	*d = digests{single}
+	return nil
+}
+
+// GetChecksums returns nil when wordpress.org publishes no checksums for the
+// version, as is the case for themes.
+func (src WordPress) GetChecksums(slug string, version string) (map[string][]string, error) {
+	switch src.Kind {
+	case constants.PluginResource:
+		return getPluginChecksums(slug, version)
+	case constants.CoreResource:
+		return getCoreChecksums(version)
+	default:
+		return nil, nil
+	}
+}
+
+func getPluginChecksums(slug string, version string) (map[string][]string, error) {
+	data, err := fetch(constants.WordPressPluginChecksums + slug + "/" + version + ".json")
+	if err == utils.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	response := struct {
+		Files map[string]struct {
+			MD5    digests `json:"md5"`
+			SHA256 digests `json:"sha256"`
+		} `json:"files"`
+	}{}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+
+	checksums := map[string][]string{}
+	for name, file := range response.Files {
+		checksums[name] = append(append([]string{}, file.MD5...), file.SHA256...)
+	}
+	return checksums, nil
+}
+
+func getCoreChecksums(version string) (map[string][]string, error) {
+	data, err := fetch(constants.WordPressCoreChecksums + version + "&locale=en_US")
+	if err == utils.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// checksums is false rather than an object for unknown versions
+	response := struct {
+		Checksums json.RawMessage `json:"checksums"`
+	}{}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	files := map[string]string{}
+	if err := json.Unmarshal(response.Checksums, &files); err != nil {
+		return nil, nil
+	}
+
+	checksums := map[string][]string{}
+	for name, digest := range files {
+		checksums[name] = []string{digest}
+	}
+	return checksums, nil
+}
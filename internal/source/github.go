@@ -0,0 +1,89 @@
+package source
+
+import (
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/github"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GitHub reads the releases of an owner/repo repository, downloading the
+// release asset matching Asset (or the first zip asset) and falling back to
+// the release zipball.
+type GitHub struct {
+	Config     *config.Config
+	Repository string
+	Asset      string
+}
+
+func (src GitHub) Load(slug string, info interface{}) error {
+	if strings.Count(src.Repository, "/") != 1 {
+		return fmt.Errorf("invalid github repository [%s], expected owner/repo", src.Repository)
+	}
+
+	releases, err := github.GetReleases(src.Config, src.Repository)
+	if err != nil {
+		return err
+	}
+
+	published := []github.Release{}
+	versions := map[string]string{}
+	dates := map[string]string{}
+	for _, release := range releases {
+		if release.Draft || release.Prerelease {
+			continue
+		}
+		version := strings.TrimPrefix(release.TagName, "v")
+		versions[version] = src.getDownload(slug, release)
+		dates[version] = release.PublishedAt
+		published = append(published, release)
+	}
+	if len(published) == 0 {
+		return fmt.Errorf("%s: no published releases found", src.Repository)
+	}
+
+	// The changelog is made of the release notes, newest first
+	sort.Slice(published, func(i, j int) bool {
+		return utils.VersionCompare(strings.TrimPrefix(published[j].TagName, "v"), strings.TrimPrefix(published[i].TagName, "v"), "<")
+	})
+	latest := published[0]
+	notes := ""
+	for _, release := range published {
+		notes += "## " + release.TagName + "\n\n" + strings.TrimSpace(release.Body) + "\n\n"
+	}
+
+	version := strings.TrimPrefix(latest.TagName, "v")
+	return decode(map[string]interface{}{
+		"version":       version,
+		"download_link": versions[version],
+		"last_updated":  latest.PublishedAt,
+		"homepage":      "https://github.com/" + src.Repository,
+		"versions":      versions,
+		"release_dates": dates,
+		"changelog_url": "https://github.com/" + src.Repository + "/releases",
+		"sections": map[string]string{
+			"changelog": notes,
+		},
+	}, info)
+}
+
+func (src GitHub) Download(url string, location string) error {
+	return github.Download(src.Config, url, location)
+}
+
+func (src GitHub) getDownload(slug string, release github.Release) string {
+	pattern := strings.ReplaceAll(src.Asset, "{slug}", slug)
+	for _, asset := range release.Assets {
+		if pattern != "" {
+			if matched, _ := filepath.Match(pattern, asset.Name); matched {
+				return asset.Url
+			}
+		} else if strings.HasSuffix(asset.Name, ".zip") {
+			return asset.Url
+		}
+	}
+	return release.ZipballUrl
+}
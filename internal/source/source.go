@@ -0,0 +1,247 @@
+package source
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/constants"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const WordPressSource = "wordpress"
+const ManifestSource = "manifest"
+const UrlSource = "url"
+const DirectorySource = "directory"
+const GitHubSource = "github"
+
+// Source loads resource information into the wordpress.org info API shape,
+// so every source can be decoded into the same info structs.
+type Source interface {
+	Load(slug string, info interface{}) error
+	Download(url string, location string) error
+}
+
+// download is embedded by sources whose download links need no credentials.
+type download struct{}
+
+func (download) Download(url string, location string) error {
+	return utils.DownloadUrl(url, location)
+}
+
+type WordPress struct {
+	download
+	Kind string
+}
+
+// Manifest reads a static JSON document in the wordpress.org info API shape.
+type Manifest struct {
+	download
+	Url string
+}
+
+// Url combines a version endpoint with a zip url, any {version} placeholder
+// in the zip url is replaced with the latest version.
+type Url struct {
+	download
+	Url        string
+	VersionUrl string
+}
+
+// Directory finds zips named <slug>-<version>.zip within a local directory.
+type Directory struct {
+	download
+	Path string
+}
+
+// ErrUpdatesDisabled is returned for resources declaring "Update URI: false".
+var ErrUpdatesDisabled = errors.New("updates disabled by Update URI header")
+
+// Get returns the source configured for a slug, or when none is configured
+// the source implied by the resources Update URI header.
+func Get(cnf *config.Config, kind string, slug string, updateUri string) (Source, error) {
+	sourceConfig := cnf.GetSource(kind, slug)
+	if sourceConfig.Type == "" {
+		return fromUpdateUri(cnf, kind, updateUri)
+	}
+
+	switch sourceConfig.Type {
+	case WordPressSource:
+		return WordPress{Kind: kind}, nil
+	case ManifestSource:
+		return Manifest{Url: expandEnv(sourceConfig.Url)}, nil
+	case UrlSource:
+		return Url{Url: expandEnv(sourceConfig.Url), VersionUrl: expandEnv(sourceConfig.VersionUrl)}, nil
+	case GitHubSource:
+		return GitHub{Config: cnf, Repository: sourceConfig.Repository, Asset: sourceConfig.Asset}, nil
+	case DirectorySource:
+		path := sourceConfig.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cnf.Cwd, path)
+		}
+		return Directory{Path: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown source type [%s]", sourceConfig.Type)
+	}
+}
+
+// expandEnv replaces $VAR and ${VAR} in configured urls with environment
+// variables, registering their values as secrets as they usually hold
+// license keys.
+func expandEnv(value string) string {
+	return os.Expand(value, func(name string) string {
+		secret := os.Getenv(name)
+		logger.AddSecret(secret)
+		return secret
+	})
+}
+
+// fromUpdateUri routes lookups for the Update URI header introduced in
+// WordPress 5.8, only wordpress.org hosted resources are looked up on
+// wordpress.org so a same named plugin can never replace a private one.
+func fromUpdateUri(cnf *config.Config, kind string, updateUri string) (Source, error) {
+	if updateUri == "" {
+		return WordPress{Kind: kind}, nil
+	}
+	if strings.EqualFold(updateUri, "false") {
+		return nil, ErrUpdatesDisabled
+	}
+
+	uri := updateUri
+	if !strings.Contains(uri, "://") {
+		uri = "https://" + uri
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Update URI [%s]", updateUri)
+	}
+
+	switch strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.") {
+	case "wordpress.org", "w.org":
+		return WordPress{Kind: kind}, nil
+	case "github.com":
+		parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+		if len(parts) >= 2 {
+			return GitHub{Config: cnf, Repository: parts[0] + "/" + strings.TrimSuffix(parts[1], ".git")}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Update URI [%s] is not hosted on wordpress.org, configure a source to update it", updateUri)
+}
+
+func (src WordPress) Load(slug string, info interface{}) error {
+	switch src.Kind {
+	case constants.PluginResource:
+		return utils.LoadWordPressApiInfo(constants.WordPressPluginApiInfo+slug, info)
+	case constants.ThemeResource:
+		return utils.LoadWordPressApiInfo(constants.WordPressThemeApiInfo+slug+"&request[fields][versions]=1", info)
+	default:
+		return fmt.Errorf("wordpress.org does not provide %s information", src.Kind)
+	}
+}
+
+func (src Manifest) Load(slug string, info interface{}) error {
+	data, err := fetch(src.Url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, info)
+}
+
+func (src Url) Load(slug string, info interface{}) error {
+	data, err := fetch(src.VersionUrl)
+	if err != nil {
+		return err
+	}
+
+	version := strings.TrimSpace(string(data))
+	if strings.HasPrefix(version, "{") {
+		decoded := struct {
+			Version string `json:"version"`
+		}{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return err
+		}
+		version = decoded.Version
+	}
+	if version == "" {
+		return fmt.Errorf("%s: no version found", src.VersionUrl)
+	}
+
+	link := strings.ReplaceAll(src.Url, "{version}", version)
+	return decode(map[string]interface{}{
+		"version":       version,
+		"download_link": link,
+		"versions":      map[string]string{version: link},
+	}, info)
+}
+
+func (src Directory) Load(slug string, info interface{}) error {
+	matches, err := filepath.Glob(filepath.Join(src.Path, slug+"*.zip"))
+	if err != nil {
+		return err
+	}
+
+	versionR := regexp.MustCompile("^" + regexp.QuoteMeta(slug) + "[-.]v?([0-9][0-9A-Za-z.\\-+]*)\\.zip$")
+	latest := ""
+	versions := map[string]string{}
+	for _, match := range matches {
+		version := versionR.FindStringSubmatch(filepath.Base(match))
+		if len(version) < 2 {
+			continue
+		}
+		versions[version[1]] = "file://" + match
+		if latest == "" || utils.VersionCompare(latest, version[1], "<") {
+			latest = version[1]
+		}
+	}
+	if latest == "" {
+		return fmt.Errorf("%s: no zips found for %s", src.Path, slug)
+	}
+
+	return decode(map[string]interface{}{
+		"version":       latest,
+		"download_link": versions[latest],
+		"versions":      versions,
+	}, info)
+}
+
+func fetch(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, logger.Error(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, utils.ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, logger.Error(fmt.Errorf("%s: unexpected status %d", url, resp.StatusCode))
+	}
+
+	return body, nil
+}
+
+// decode round trips values through JSON so they populate any info struct
+// using the wordpress.org field names.
+func decode(values map[string]interface{}, info interface{}) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, info)
+}
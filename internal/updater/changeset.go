@@ -0,0 +1,231 @@
+package updater
+
+import (
+	"errors"
+	"github.com/wpgitupdater/wpgitupdater/internal/api"
+	"github.com/wpgitupdater/wpgitupdater/internal/changelog"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/git"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"github.com/wpgitupdater/wpgitupdater/internal/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bodyLimit keeps pull request bodies, along with their marker, within the
+// 65536 characters GitHub accepts however many resources they update.
+const bodyLimit = 65000
+
+// markerR finds the branch a pull request was last updated with, which
+// differs from its head once a newer version is pushed onto it.
+var markerR = regexp.MustCompile(`<!-- wpgitupdater:(\S+) -->`)
+
+// changeSet is a branch of one or more updates along with its pull request.
+// Branches of earlier versions of the same resources share Prefix, followed
+// by a remainder matching Pattern.
+type changeSet struct {
+	Name        string
+	Branch      string
+	Prefix      string
+	Pattern     *regexp.Regexp
+	Resources   []interfaces.Resource
+	PullRequest func(downloads []Download) (interfaces.PullRequest, error)
+}
+
+// publish stages every update of the change set, commits them onto a branch
+// and opens a pull request. Open pull requests of earlier versions are
+// either closed as superseded or, when configured, reused by force pushing
+// the newer versions onto their branch.
+func (cs changeSet) publish(cnf *config.Config, stats bool) error {
+	p, err := provider.Get(cnf)
+	if err != nil {
+		return err
+	}
+
+	open, err := p.FindPullRequests(cs.Prefix)
+	if err != nil {
+		return err
+	}
+	stale := []interfaces.PullRequest{}
+	for _, pr := range open {
+		if pr.Head == cs.Branch {
+			logger.Printf("[%s] Pull request already open, skipping\n", cs.Name)
+			return SkipError{"pull request open"}
+		}
+		if cs.Pattern.MatchString(strings.TrimPrefix(pr.Head, cs.Prefix)) {
+			stale = append(stale, pr)
+		}
+	}
+
+	reuse := cnf.UpdatesSuperseded() && len(stale) > 0
+	if reuse && getMarker(stale[0]) == cs.Branch {
+		logger.Printf("[%s] Pull request #%d already up to date, skipping\n", cs.Name, stale[0].Number)
+		return SkipError{"pull request up to date"}
+	}
+
+	// Rolling back a failed update discards every change in the tree
+	if dirty, err := git.HasChanges(); err != nil {
+		return err
+	} else if dirty {
+		return errors.New("the working tree has uncommitted changes, commit or stash them before updating")
+	}
+
+	for _, r := range cs.Resources {
+		if err := api.UpdateUsage(r.GetKind(), r.GetSlug(), stats); err != nil {
+			return err
+		}
+	}
+	logger.Printf("[%s] Usage updated...\n", cs.Name)
+
+	tmpDir, err := ioutil.TempDir("", "wpgitupdater-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	downloads := []Download{}
+	for i, r := range cs.Resources {
+		download, err := prepareUpdate(r, filepath.Join(tmpDir, strconv.Itoa(i)))
+		if err != nil {
+			return err
+		}
+		downloads = append(downloads, download)
+	}
+
+	sourceBranch, err := git.CurrentBranch()
+	if err != nil {
+		return err
+	}
+
+	branchName := cs.Branch
+	checkout := []string{"checkout", "-b", branchName}
+	push := []string{"push", "-u", "origin", branchName}
+	if reuse {
+		branchName = stale[0].Head
+		logger.Printf("Replacing Branch [%v] of pull request #%d\n", branchName, stale[0].Number)
+		checkout = []string{"checkout", "-B", branchName}
+		push = []string{"push", "--force", "-u", "origin", branchName}
+	} else {
+		logger.Printf("Creating Branch [%v]\n", branchName)
+	}
+	output, err := git.Run(checkout...)
+	logger.Println(output)
+	if err != nil {
+		return err
+	}
+
+	if err := applyUpdates(cnf, downloads, sourceBranch, branchName); err != nil {
+		return err
+	}
+
+	logger.Printf("Pushing update for [%v]\n", cs.Name)
+	output, err = git.Run(push...)
+	logger.Println(output)
+	if err != nil {
+		rollback(sourceBranch, branchName)
+		return err
+	}
+
+	logger.Println("Restoring local branch")
+	output, err = git.Run("checkout", sourceBranch)
+	logger.Println(output)
+	if err != nil {
+		return err
+	}
+
+	pr, err := cs.PullRequest(downloads)
+	if err != nil {
+		return err
+	}
+	pr.Head = branchName
+	if len(pr.Body) > bodyLimit {
+		pr.Body = changelog.Truncate(pr.Body, bodyLimit) + "\n\n_Pull request body truncated._"
+	}
+	pr.Body += "\n\n<!-- wpgitupdater:" + cs.Branch + " -->"
+	if pr.Base == "" {
+		pr.Base = cnf.Branch
+	}
+	if pr.Base == "" {
+		pr.Base = sourceBranch
+	}
+
+	if reuse {
+		logger.Printf("Updating pull request #%d\n", stale[0].Number)
+		pr.Number = stale[0].Number
+		pr.Url = stale[0].Url
+		if err := p.UpdatePullRequest(pr); err != nil {
+			return err
+		}
+		stale = stale[1:]
+	} else {
+		logger.Println("Creating pull request")
+		created, err := p.CreatePullRequest(pr)
+		if err != nil {
+			return err
+		}
+		pr.Number = created.Number
+		pr.Url = created.Url
+		logger.Printf("Created pull request #%d [%s]\n", pr.Number, pr.Url)
+	}
+
+	cs.applyOptions(p, pr)
+
+	for _, superseded := range stale {
+		logger.Printf("Closing superseded pull request #%d\n", superseded.Number)
+		if err := p.ClosePullRequest(superseded, "Superseded by "+pr.Url); err != nil {
+			logger.Println(err)
+		}
+	}
+
+	return nil
+}
+
+// applyOptions adds the configured labels, reviewers, assignees and
+// milestone to an opened pull request and enables auto-merge. Failures are
+// reported without failing the update, as the pull request itself is open.
+func (cs changeSet) applyOptions(p interfaces.Provider, pr interfaces.PullRequest) {
+	if len(pr.Labels) > 0 {
+		logger.Printf("Adding labels [%s]\n", strings.Join(pr.Labels, ", "))
+		if err := p.AddLabels(pr, pr.Labels); err != nil {
+			logger.Printf("[%s] Unable to add labels, %s\n", cs.Name, err)
+		}
+	}
+	if len(pr.Reviewers) > 0 || len(pr.TeamReviewers) > 0 {
+		logger.Printf("Requesting reviewers [%s]\n", strings.Join(append(append([]string{}, pr.Reviewers...), pr.TeamReviewers...), ", "))
+		if err := p.RequestReviewers(pr, pr.Reviewers, pr.TeamReviewers); err != nil {
+			logger.Printf("[%s] Unable to request reviewers, %s\n", cs.Name, err)
+		}
+	}
+	if len(pr.Assignees) > 0 {
+		logger.Printf("Adding assignees [%s]\n", strings.Join(pr.Assignees, ", "))
+		if err := p.AddAssignees(pr, pr.Assignees); err != nil {
+			logger.Printf("[%s] Unable to add assignees, %s\n", cs.Name, err)
+		}
+	}
+	if pr.Milestone != "" {
+		logger.Printf("Setting milestone [%s]\n", pr.Milestone)
+		if err := p.SetMilestone(pr, pr.Milestone); err != nil {
+			logger.Printf("[%s] Unable to set milestone, %s\n", cs.Name, err)
+		}
+	}
+	if pr.AutoMerge != "" {
+		logger.Printf("Enabling %s auto-merge\n", pr.AutoMerge)
+		if err := p.EnableAutoMerge(pr, pr.AutoMerge); err != nil {
+			logger.Printf("[%s] Unable to enable auto-merge, %s\n", cs.Name, err)
+		}
+	}
+}
+
+// getMarker returns the branch recorded in the body of a pull request opened
+// by the updater, or its head when missing.
+func getMarker(pr interfaces.PullRequest) string {
+	if match := markerR.FindStringSubmatch(pr.Body); match != nil {
+		return match[1]
+	}
+	return pr.Head
+}
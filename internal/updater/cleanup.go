@@ -0,0 +1,107 @@
+package updater
+
+import (
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/git"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"github.com/wpgitupdater/wpgitupdater/internal/provider"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"strings"
+)
+
+// Cleanup deletes the update branches on origin whose pull request was merged
+// or closed, and those updating a resource to a version no newer than the
+// installed one, closing their pull request when still open.
+func Cleanup(cnf *config.Config, resources []interfaces.Resource, dryRun bool) error {
+	branches, err := git.RemoteBranches("wpgitupdates-*")
+	if err != nil {
+		return err
+	}
+	p, err := provider.Get(cnf)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, branch := range branches {
+		prs, err := p.GetPullRequests(branch)
+		if err != nil {
+			logger.Printf("%-60v[failed (%s)]\n", branch, err)
+			failed++
+			continue
+		}
+
+		reason, open := getCleanupReason(resources, branch, prs)
+		if reason == "" {
+			logger.Printf("%-60v[kept]\n", branch)
+			continue
+		}
+		if dryRun {
+			logger.Printf("%-60v[deletable (%s)]\n", branch, reason)
+			continue
+		}
+
+		for _, pr := range open {
+			logger.Printf("Closing pull request #%d\n", pr.Number)
+			if err := p.ClosePullRequest(pr, "Closed as "+reason); err != nil {
+				logger.Println(err)
+			}
+		}
+		output, err := git.Run("push", "origin", "--delete", branch)
+		logger.Println(output)
+		if err != nil {
+			logger.Printf("%-60v[failed (%s)]\n", branch, err)
+			failed++
+			continue
+		}
+		logger.Printf("%-60v[deleted (%s)]\n", branch, reason)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d branches could not be cleaned up", failed)
+	}
+	return nil
+}
+
+// getCleanupReason explains why an update branch can be deleted, or returns
+// an empty string when it must be kept, along with its open pull requests.
+func getCleanupReason(resources []interfaces.Resource, branch string, prs []interfaces.PullRequest) (string, []interfaces.PullRequest) {
+	open := []interfaces.PullRequest{}
+	closed := ""
+	for _, pr := range prs {
+		switch pr.State {
+		case interfaces.PullRequestOpen:
+			open = append(open, pr)
+		case interfaces.PullRequestMerged:
+			closed = "pull request merged"
+		case interfaces.PullRequestClosed:
+			if closed == "" {
+				closed = "pull request closed"
+			}
+		}
+	}
+
+	// Branches reused for newer versions record them in the pull request
+	target := branch
+	if len(open) > 0 {
+		target = getMarker(open[0])
+	}
+	for _, r := range resources {
+		prefix := "wpgitupdates-" + r.GetKind() + "-" + r.GetSlug() + "-"
+		rest := strings.TrimPrefix(target, prefix)
+		if !strings.HasPrefix(target, prefix) || !versionsR.MatchString(rest) {
+			continue
+		}
+		version := rest[strings.Index(rest, "-")+1:]
+		if !utils.VersionCompare(r.GetInstalledVersion(), version, "<") {
+			return "outdated, " + r.GetInstalledVersion() + " installed", open
+		}
+	}
+
+	if len(open) > 0 {
+		return "", open
+	}
+	return closed, open
+}
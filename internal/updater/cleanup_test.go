@@ -0,0 +1,48 @@
+package updater
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"reflect"
+	"testing"
+)
+
+func TestGetCleanupReason(t *testing.T) {
+	resources := []interfaces.Resource{
+		Package{Kind: "plugin", Slug: "akismet", Version: "4.1"},
+		Package{Kind: "theme", Slug: "twentytwenty", Version: "1.5"},
+	}
+	open := func(head string) interfaces.PullRequest {
+		return interfaces.PullRequest{Number: 3, State: interfaces.PullRequestOpen, Head: head}
+	}
+	reused := interfaces.PullRequest{Number: 4, State: interfaces.PullRequestOpen, Head: "wpgitupdates-plugin-akismet-4.0-4.1", Body: "Changes\n\n<!-- wpgitupdater:wpgitupdates-plugin-akismet-4.0-4.2 -->"}
+	merged := interfaces.PullRequest{Number: 1, State: interfaces.PullRequestMerged}
+	closed := interfaces.PullRequest{Number: 2, State: interfaces.PullRequestClosed}
+	tests := []struct {
+		name   string
+		branch string
+		prs    []interfaces.PullRequest
+		reason string
+		open   []interfaces.PullRequest
+	}{
+		{"merged", "wpgitupdates-plugin-hello-1.0-1.1", []interfaces.PullRequest{closed, merged}, "pull request merged", []interfaces.PullRequest{}},
+		{"closed", "wpgitupdates-plugin-hello-1.0-1.1", []interfaces.PullRequest{closed}, "pull request closed", []interfaces.PullRequest{}},
+		{"open", "wpgitupdates-plugin-hello-1.0-1.1", []interfaces.PullRequest{open("wpgitupdates-plugin-hello-1.0-1.1")}, "", []interfaces.PullRequest{open("wpgitupdates-plugin-hello-1.0-1.1")}},
+		{"no pull request", "wpgitupdates-plugin-hello-1.0-1.1", []interfaces.PullRequest{}, "", []interfaces.PullRequest{}},
+		{"outdated", "wpgitupdates-plugin-akismet-4.0-4.1", []interfaces.PullRequest{open("wpgitupdates-plugin-akismet-4.0-4.1")}, "outdated, 4.1 installed", []interfaces.PullRequest{open("wpgitupdates-plugin-akismet-4.0-4.1")}},
+		{"outdated without pull request", "wpgitupdates-theme-twentytwenty-1.4-1.5", []interfaces.PullRequest{}, "outdated, 1.5 installed", []interfaces.PullRequest{}},
+		{"pending", "wpgitupdates-plugin-akismet-4.1-4.2", []interfaces.PullRequest{open("wpgitupdates-plugin-akismet-4.1-4.2")}, "", []interfaces.PullRequest{open("wpgitupdates-plugin-akismet-4.1-4.2")}},
+		{"reused for a newer version", "wpgitupdates-plugin-akismet-4.0-4.1", []interfaces.PullRequest{reused}, "", []interfaces.PullRequest{reused}},
+		{"other kind", "wpgitupdates-theme-akismet-4.0-4.1", []interfaces.PullRequest{open("wpgitupdates-theme-akismet-4.0-4.1")}, "", []interfaces.PullRequest{open("wpgitupdates-theme-akismet-4.0-4.1")}},
+		{"group", "wpgitupdates-group-weekly-0123abcd", []interfaces.PullRequest{merged}, "pull request merged", []interfaces.PullRequest{}},
+	}
+
+	for _, test := range tests {
+		reason, pending := getCleanupReason(resources, test.branch, test.prs)
+		if reason != test.reason {
+			t.Errorf("%s: got reason %q, want %q", test.name, reason, test.reason)
+		}
+		if !reflect.DeepEqual(pending, test.open) {
+			t.Errorf("%s: got open pull requests %v, want %v", test.name, pending, test.open)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package updater
+
+import (
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+)
+
+// CheckCompatibility compares the requirements of the available version with
+// the site environment, returning requirements the site does not meet and
+// warnings that never block an update, including when the requirements are
+// unknown.
+func CheckCompatibility(cnf *config.Config, r interfaces.Resource) ([]string, []string) {
+	problems := []string{}
+	warnings := []string{}
+	requirements := r.GetRequirements()
+	if requirements.Unknown {
+		warnings = append(warnings, "requirements of "+r.GetAvailableVersion()+" unknown")
+		return problems, warnings
+	}
+
+	php := cnf.GetPHPVersion()
+	if requirements.PHP != "" && php != "" && utils.VersionCompare(php, requirements.PHP, "<") {
+		problems = append(problems, fmt.Sprintf("requires PHP %s, site runs %s", requirements.PHP, php))
+	}
+
+	wordpress := cnf.GetWordPressVersion()
+	if requirements.WordPress != "" && wordpress != "" && utils.VersionCompare(wordpress, requirements.WordPress, "<") {
+		problems = append(problems, fmt.Sprintf("requires WordPress %s, site runs %s", requirements.WordPress, wordpress))
+	}
+	if requirements.Tested != "" && wordpress != "" && utils.VersionBump(requirements.Tested, wordpress) != "patch" && utils.VersionCompare(wordpress, requirements.Tested, ">") {
+		warnings = append(warnings, fmt.Sprintf("tested up to WordPress %s, site runs %s", requirements.Tested, wordpress))
+	}
+
+	return problems, warnings
+}
+
+func getPRCompatibility(cnf *config.Config, r interfaces.Resource) string {
+	problems, warnings := CheckCompatibility(cnf, r)
+	if len(problems) == 0 && len(warnings) == 0 {
+		return ""
+	}
+
+	body := "**Compatibility:**\n\n"
+	for _, problem := range problems {
+		body += "- :warning: " + problem + "\n"
+	}
+	for _, warning := range warnings {
+		body += "- " + warning + "\n"
+	}
+	return body + "\n"
+}
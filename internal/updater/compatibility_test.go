@@ -0,0 +1,59 @@
+package updater
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"reflect"
+	"testing"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		requires string
+		php      string
+		tested   string
+		problems []string
+		warnings []string
+	}{
+		{"compatible", "4.1", "5.8", "7.4", "6.2", []string{}, []string{}},
+		{"requirements unset", "4.1", "", "", "", []string{}, []string{}},
+		{"php too old", "4.1", "5.8", "8.0", "6.1", []string{"requires PHP 8.0, site runs 7.4"}, []string{}},
+		{"wordpress too old", "4.1", "6.2", "7.4", "6.2", []string{"requires WordPress 6.2, site runs 6.1.1"}, []string{}},
+		{"tested up to the same minor", "4.1", "5.8", "7.4", "6.1", []string{}, []string{}},
+		{"not tested", "4.1", "5.8", "7.4", "6.0", []string{}, []string{"tested up to WordPress 6.0, site runs 6.1.1"}},
+		{"requirements unknown", "4.0.1", "6.2", "8.0", "6.0", []string{}, []string{"requirements of 4.0.1 unknown"}},
+	}
+
+	cnf := &config.Config{Environment: config.EnvironmentConfig{PHP: "7.4", WordPress: "6.1.1"}}
+	for _, test := range tests {
+		pkg := Package{Kind: "plugin", Slug: "akismet", Version: "4.0", Target: test.target, Info: PackageInfo{Version: "4.1", Requires: utils.WordPressString(test.requires), RequiresPHP: utils.WordPressString(test.php), Tested: utils.WordPressString(test.tested)}}
+		problems, warnings := CheckCompatibility(cnf, pkg)
+		if !reflect.DeepEqual(problems, test.problems) {
+			t.Errorf("%s: got problems %q, want %q", test.name, problems, test.problems)
+		}
+		if !reflect.DeepEqual(warnings, test.warnings) {
+			t.Errorf("%s: got warnings %q, want %q", test.name, warnings, test.warnings)
+		}
+	}
+}
+
+func TestGetHeldBackReasonIncompatible(t *testing.T) {
+	pkg := Package{Kind: "plugin", Slug: "akismet", Version: "4.0", Target: "4.1", Info: PackageInfo{Version: "4.1", RequiresPHP: "8.0"}}
+	tests := []struct {
+		compatibility string
+		want          string
+	}{
+		{"", "incompatible, requires PHP 8.0, site runs 7.4"},
+		{"block", "incompatible, requires PHP 8.0, site runs 7.4"},
+		{"warn", ""},
+	}
+
+	for _, test := range tests {
+		cnf := &config.Config{Compatibility: test.compatibility, Environment: config.EnvironmentConfig{PHP: "7.4"}}
+		if got := GetHeldBackReason(cnf, pkg); got != test.want {
+			t.Errorf("compatibility %q: GetHeldBackReason = %q, want %q", test.compatibility, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package updater
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/git"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Group is a set of pending updates that share a branch and pull request.
+type Group struct {
+	Config        config.GroupConfig
+	Resources     []interfaces.Resource
+	Verifications map[string]string
+}
+
+// digestR matches the digest ending group branch names.
+var digestR = regexp.MustCompile(`^[0-9a-f]{8}$`)
+
+// GetBranchName includes a digest of every bump in the group so a new branch
+// is only created when the set of pending versions changes.
+func (group Group) GetBranchName() string {
+	hash := sha1.New()
+	for _, r := range group.Resources {
+		hash.Write([]byte(GetBranchName(r) + "\n"))
+	}
+	return group.getPrefix() + hex.EncodeToString(hash.Sum(nil))[:8]
+}
+
+// getPrefix is shared by the branches of every version of the group.
+func (group Group) getPrefix() string {
+	return "wpgitupdates-group-" + group.Config.GetSlug() + "-"
+}
+
+func (group Group) GetPRTitle() string {
+	msg := strings.ReplaceAll(group.Config.GetPRTitle(), ":group", group.Config.Name)
+	msg = strings.ReplaceAll(msg, ":count", strconv.Itoa(len(group.Resources)))
+	return msg
+}
+
+func (group Group) GetPRBody(cnf *config.Config) string {
+	body := getPRHeader() + "\n\n**Updates:**\n\n| Resource | Type | From | To |\n| --- | --- | --- | --- |\n"
+	for _, r := range group.Resources {
+		body += fmt.Sprintf("| %s (%s) | %s | %s | %s |\n", r.GetName(), r.GetSlug(), r.GetKind(), r.GetInstalledVersion(), r.GetAvailableVersion())
+	}
+	for _, r := range group.Resources {
+		body += fmt.Sprintf("\n### %s %s to %s\n\n", r.GetName(), r.GetInstalledVersion(), r.GetAvailableVersion()) + getPRDetails(cnf, r, group.Verifications[r.GetKind()+"/"+r.GetSlug()]) + "\n"
+	}
+	return body
+}
+
+// GetLabels combines the labels configured for every resource in the group.
+func (group Group) GetLabels(cnf *config.Config) []string {
+	return group.combine(func(r interfaces.Resource) []string {
+		return cnf.GetLabels(r.GetKind(), r.GetSlug())
+	})
+}
+
+func (group Group) GetReviewers(cnf *config.Config) []string {
+	return group.combine(func(r interfaces.Resource) []string {
+		return cnf.GetReviewers(r.GetKind())
+	})
+}
+
+func (group Group) GetTeamReviewers(cnf *config.Config) []string {
+	return group.combine(func(r interfaces.Resource) []string {
+		return cnf.GetTeamReviewers(r.GetKind())
+	})
+}
+
+func (group Group) GetAssignees(cnf *config.Config) []string {
+	return group.combine(func(r interfaces.Resource) []string {
+		return cnf.GetAssignees(r.GetKind())
+	})
+}
+
+// GetMilestone returns the milestone of the first resource with one.
+func (group Group) GetMilestone(cnf *config.Config) string {
+	for _, r := range group.Resources {
+		if milestone := cnf.GetMilestone(r.GetKind()); milestone != "" {
+			return milestone
+		}
+	}
+	return ""
+}
+
+// IsDraft reports whether any update in the group opens as a draft.
+func (group Group) IsDraft(cnf *config.Config) bool {
+	for _, r := range group.Resources {
+		if getBump(cnf, r).Draft {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAutoMerge returns the merge method when every update in the group is
+// configured to auto-merge with the same method.
+func (group Group) GetAutoMerge(cnf *config.Config) string {
+	method := ""
+	for i, r := range group.Resources {
+		automerge := getBump(cnf, r).Automerge
+		if automerge == "" || (i > 0 && automerge != method) {
+			return ""
+		}
+		method = automerge
+	}
+	return method
+}
+
+// combine returns the distinct values get returns for every resource.
+func (group Group) combine(get func(r interfaces.Resource) []string) []string {
+	values := []string{}
+	for _, r := range group.Resources {
+		for _, value := range get(r) {
+			if _, exists := utils.InSlice(values, value); !exists {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+func (group Group) UpdateBranchExists() bool {
+	return git.BranchExists(group.GetBranchName())
+}
+
+func (group *Group) PerformUpdate(cnf *config.Config, dryRun bool, stats bool) error {
+	name := group.Config.Name
+
+	if group.UpdateBranchExists() {
+		logger.Printf("[group %s] Update branch exists, skipping\n", name)
+		return SkipError{"update branch exists"}
+	}
+
+	if dryRun {
+		for _, r := range group.Resources {
+			logger.Printf("[group %s] [%s] %s to %s\n", name, r.GetSlug(), r.GetInstalledVersion(), r.GetAvailableVersion())
+		}
+		logger.Printf("[group %s] Skipping actual update process...\n", name)
+		return SkipError{"dry run, group " + name}
+	}
+
+	cs := changeSet{
+		Name:      "group " + name,
+		Branch:    group.GetBranchName(),
+		Prefix:    group.getPrefix(),
+		Pattern:   digestR,
+		Resources: group.Resources,
+		PullRequest: func(downloads []Download) (interfaces.PullRequest, error) {
+			group.Verifications = map[string]string{}
+			for _, download := range downloads {
+				group.Verifications[download.Resource.GetKind()+"/"+download.Resource.GetSlug()] = download.Summary
+			}
+			return group.GetPullRequest(cnf, downloads)
+		},
+	}
+	return cs.publish(cnf, stats)
+}
+
+// GetPullRequest renders the group_title and group_body templates when
+// defined, otherwise the configured title and the default body.
+func (group Group) GetPullRequest(cnf *config.Config, downloads []Download) (interfaces.PullRequest, error) {
+	title, found, err := renderGroupTemplate(cnf, group, downloads, "group_title")
+	if err != nil {
+		return interfaces.PullRequest{}, err
+	} else if !found {
+		title = group.GetPRTitle()
+	}
+	body, found, err := renderGroupTemplate(cnf, group, downloads, "group_body")
+	if err != nil {
+		return interfaces.PullRequest{}, err
+	} else if !found {
+		body = group.GetPRBody(cnf)
+	}
+
+	return interfaces.PullRequest{
+		Title:  title,
+		Head:   group.GetBranchName(),
+		Body:   body,
+		Labels: group.GetLabels(cnf),
+		Draft:  group.IsDraft(cnf),
+
+		Reviewers:     group.GetReviewers(cnf),
+		TeamReviewers: group.GetTeamReviewers(cnf),
+		Assignees:     group.GetAssignees(cnf),
+		Milestone:     group.GetMilestone(cnf),
+		AutoMerge:     group.GetAutoMerge(cnf),
+	}, nil
+}
@@ -0,0 +1,214 @@
+package updater
+
+import (
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/git"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Download is a verified and staged copy of the available version of a
+// resource, extracted outside of the repository.
+type Download struct {
+	Resource  interfaces.Resource
+	Archive   string
+	Staged    string
+	Checksums map[string][]string
+	Summary   string
+	// FilesChanged is counted once the update has been applied.
+	FilesChanged int
+}
+
+// prepareUpdate downloads the available version of a resource into dir,
+// verifies it against any published checksums and stages the extracted copy,
+// before anything in the repository has been touched.
+func prepareUpdate(r interfaces.Resource, dir string) (Download, error) {
+	slug := r.GetSlug()
+	kind := r.GetKind()
+	download := Download{Resource: r}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return download, err
+	}
+	download.Archive = filepath.Join(dir, getArchiveName(r.GetDownloadUrl()))
+
+	logger.Printf("Downloading new %s version for [%v]\n", kind, slug)
+	var err error
+	if downloader, ok := r.(interfaces.Downloader); ok {
+		err = downloader.Download(r.GetDownloadUrl(), download.Archive)
+	} else {
+		err = utils.DownloadUrl(r.GetDownloadUrl(), download.Archive)
+	}
+	if err != nil {
+		return download, err
+	}
+
+	if verifier, ok := r.(interfaces.Verifier); ok {
+		logger.Printf("Verifying %s archive for [%v]\n", kind, slug)
+		if download.Checksums, err = verifier.GetChecksums(); err != nil {
+			return download, err
+		}
+	}
+
+	if download.Checksums == nil {
+		download.Summary = "Checksums are not published for this version, files were not verified"
+	} else {
+		skip := []string{}
+		if preserver, ok := r.(interfaces.Preserver); ok {
+			skip = preserver.GetPreserved()
+		}
+		verified, err := utils.VerifyZip(download.Archive, download.Checksums, skip)
+		if err != nil {
+			return download, fmt.Errorf("[%s] archive verification failed, %s", slug, err)
+		}
+		download.Summary = fmt.Sprintf("%d files verified against wordpress.org checksums", verified)
+	}
+
+	logger.Printf("Staging new %s version for [%v]\n", kind, slug)
+	base := filepath.Base(r.GetInstallDir())
+	if err := utils.NormaliseZip(download.Archive, base); err != nil {
+		return download, err
+	}
+	stageDir := filepath.Join(dir, "staged")
+	if _, err := utils.Unzip(download.Archive, stageDir); err != nil {
+		return download, err
+	}
+	download.Staged = filepath.Join(stageDir, base)
+
+	if validator, ok := r.(interfaces.Validator); ok {
+		if err := validator.Validate(download.Staged); err != nil {
+			return download, fmt.Errorf("[%s] %s", slug, err)
+		}
+	}
+
+	if download.Checksums != nil {
+		if _, err := utils.VerifyFiles(download.Staged, download.Checksums); err != nil {
+			return download, fmt.Errorf("[%s] staged file verification failed, %s", slug, err)
+		}
+	}
+
+	return download, nil
+}
+
+// getArchiveName names a downloaded archive after the last segment of its
+// url path, leaving out the query which may hold license keys.
+func getArchiveName(link string) string {
+	if parsed, err := url.Parse(link); err == nil {
+		if name := path.Base(parsed.Path); name != "." && name != "/" {
+			return name
+		}
+	}
+	return "archive.zip"
+}
+
+// applyUpdates applies every staged update onto the checked out update
+// branch, rolling back to sourceBranch when any of them fails.
+func applyUpdates(cnf *config.Config, downloads []Download, sourceBranch string, branchName string) error {
+	for i := range downloads {
+		if err := applyUpdate(cnf, &downloads[i]); err != nil {
+			rollback(sourceBranch, branchName)
+			return err
+		}
+	}
+	return nil
+}
+
+// applyUpdate replaces the installed copy of a resource with its staged copy
+// on the current branch and commits the result. The install directory is
+// swapped by renames so it is never left partially extracted.
+func applyUpdate(cnf *config.Config, download *Download) error {
+	r := download.Resource
+	slug := r.GetSlug()
+	kind := r.GetKind()
+
+	logger.Printf("Installing new %s version for [%v]\n", kind, slug)
+	if installer, ok := r.(interfaces.Installer); ok {
+		if err := installer.Install(download.Staged); err != nil {
+			return err
+		}
+	} else if err := swapDir(download.Staged, r.GetInstallDir()); err != nil {
+		return err
+	}
+
+	logger.Printf("Commiting %s update for [%v]\n", kind, slug)
+	output, err := git.Run("add", "-A", ".")
+	logger.Println(output)
+	if err != nil {
+		return err
+	}
+
+	output, err = git.Run("diff", "--cached", "--name-only")
+	if err != nil {
+		return err
+	}
+	download.FilesChanged = len(strings.Fields(output))
+
+	message, err := getCommitMessage(cnf, *download)
+	if err != nil {
+		return err
+	}
+	output, err = git.Run("commit", "-a", "-m", message)
+	logger.Println(output)
+	return err
+}
+
+// swapDir replaces dest with src. src is first moved alongside dest, so the
+// final swap is a rename within the same directory, and dest is put back if
+// the swap fails.
+func swapDir(src string, dest string) error {
+	swap, err := ioutil.TempDir(filepath.Dir(dest), "."+filepath.Base(dest)+"-wpgitupdater-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(swap)
+
+	incoming := filepath.Join(swap, "new")
+	if err := os.Rename(src, incoming); err != nil {
+		// Staging is usually on another filesystem
+		if err := os.MkdirAll(incoming, os.ModePerm); err != nil {
+			return err
+		}
+		if err := utils.CopyDir(src, incoming, nil); err != nil {
+			return err
+		}
+	}
+
+	previous := filepath.Join(swap, "old")
+	if err := os.Rename(dest, previous); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(incoming, dest); err != nil {
+		if restoreErr := os.Rename(previous, dest); restoreErr != nil {
+			return fmt.Errorf("%s, unable to restore %s: %s", err, dest, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// rollback restores the tree of the source branch after a failed update and
+// deletes the local update branch. publish only starts from a clean tree, so
+// only changes made by the update are discarded.
+func rollback(sourceBranch string, branchName string) {
+	logger.Println("Rolling back update")
+	for _, cmd := range [][]string{
+		{"reset", "--hard", "-q"},
+		{"clean", "-fdq"},
+		{"checkout", sourceBranch},
+		{"branch", "-D", branchName},
+	} {
+		output, err := git.Run(cmd...)
+		logger.Println(output)
+		if err != nil {
+			logger.Println(err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package updater
+
+import (
+	"archive/zip"
+	"errors"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/git"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/source"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newRepository creates a git repository on branch main committing files,
+// and changes into it until the test ends.
+func newRepository(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "wpgitupdater-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(cwd)
+		os.RemoveAll(dir)
+	})
+
+	writeFiles(t, dir, files)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"checkout", "-q", "-b", "main"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test"},
+		{"add", "-A"},
+		{"commit", "-q", "-m", "Initial"},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s %s", strings.Join(args, " "), err, output)
+		}
+	}
+	return dir
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func writeZip(t *testing.T, src string, files map[string]string) {
+	out, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	w := zip.NewWriter(out)
+	for name, content := range files {
+		writer, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, file string) string {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// newZipPackage returns a plugin of the repository in dir updating from
+// version to target, downloaded from a zip of files beside the repository.
+func newZipPackage(t *testing.T, dir string, slug string, version string, target string, files map[string]string) Package {
+	zips := dir + "-zips"
+	if err := os.MkdirAll(zips, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(zips)
+	})
+	archive := filepath.Join(zips, slug+"-"+target+".zip")
+	writeZip(t, archive, files)
+	return Package{
+		Kind:       "plugin",
+		NameHeader: "Plugin Name",
+		HeaderFile: "*.php",
+		Slug:       slug,
+		Path:       filepath.Join(dir, "plugins", slug),
+		Version:    version,
+		Target:     target,
+		Info:       PackageInfo{Version: target, Download: "file://" + archive},
+		Source:     source.Directory{Path: zips},
+	}
+}
+
+const akismet = "<?php\n/*\nPlugin Name: Akismet\nVersion: 4.0\n*/\n"
+const hello = "<?php\n/*\nPlugin Name: Hello\nVersion: 1.0\n*/\n"
+
+func TestPrepareUpdateValidation(t *testing.T) {
+	dir := newRepository(t, map[string]string{"plugins/akismet/akismet.php": akismet})
+	pkg := newZipPackage(t, dir, "akismet", "4.0", "4.1", map[string]string{
+		"akismet/akismet.php": strings.Replace(akismet, "4.0", "4.0.1", 1),
+	})
+
+	tmpDir, err := ioutil.TempDir("", "wpgitupdater-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	_, err = prepareUpdate(pkg, tmpDir)
+	if err == nil || err.Error() != "[akismet] staged plugin is version 4.0.1, expected 4.1" {
+		t.Errorf("got error %v, want a version mismatch", err)
+	}
+	if got := readFile(t, filepath.Join(dir, "plugins/akismet/akismet.php")); got != akismet {
+		t.Errorf("installed copy changed to %q", got)
+	}
+}
+
+// failingInstall swaps its staged copy into place and then fails, as an
+// install rejected once files were already replaced would.
+type failingInstall struct {
+	Package
+}
+
+func (r failingInstall) Install(staged string) error {
+	if err := swapDir(staged, r.GetInstallDir()); err != nil {
+		return err
+	}
+	return errors.New("installed plugin failed validation")
+}
+
+func TestApplyUpdatesRollback(t *testing.T) {
+	dir := newRepository(t, map[string]string{
+		"plugins/akismet/akismet.php": akismet,
+		"plugins/hello/hello.php":     hello,
+	})
+	cnf := &config.Config{Cwd: dir}
+	resources := []interfaces.Resource{
+		newZipPackage(t, dir, "akismet", "4.0", "4.1", map[string]string{
+			"akismet/akismet.php": strings.Replace(akismet, "4.0", "4.1", 1),
+			"akismet/new.php":     "<?php",
+		}),
+		failingInstall{newZipPackage(t, dir, "hello", "1.0", "1.1", map[string]string{
+			"hello/hello.php": strings.Replace(hello, "1.0", "1.1", 1),
+		})},
+	}
+
+	tmpDir, err := ioutil.TempDir("", "wpgitupdater-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	downloads := []Download{}
+	for i, r := range resources {
+		download, err := prepareUpdate(r, filepath.Join(tmpDir, strconv.Itoa(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		downloads = append(downloads, download)
+	}
+
+	if _, err := git.Run("checkout", "-q", "-b", "wpgitupdates-test"); err != nil {
+		t.Fatal(err)
+	}
+	err = applyUpdates(cnf, downloads, "main", "wpgitupdates-test")
+	if err == nil || err.Error() != "installed plugin failed validation" {
+		t.Fatalf("got error %v, want the install failure", err)
+	}
+
+	if branch, _ := git.CurrentBranch(); branch != "main" {
+		t.Errorf("on branch [%s], want main", branch)
+	}
+	if _, err := git.Run("rev-parse", "--verify", "-q", "wpgitupdates-test"); err == nil {
+		t.Error("update branch was not deleted")
+	}
+	if got := readFile(t, filepath.Join(dir, "plugins/akismet/akismet.php")); got != akismet {
+		t.Errorf("akismet.php is %q, want the original", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "plugins/hello/hello.php")); got != hello {
+		t.Errorf("hello.php is %q, want the original", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "plugins/akismet/new.php")); !os.IsNotExist(err) {
+		t.Error("new.php of the rolled back update remains")
+	}
+	if dirty, _ := git.HasChanges(); dirty {
+		t.Error("working tree has changes after rollback")
+	}
+}
@@ -0,0 +1,177 @@
+package updater
+
+import (
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/changelog"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/source"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"path/filepath"
+)
+
+// PackageInfo describes a plugin or theme in the format of the wordpress.org
+// API, which every source loads its info into.
+type PackageInfo struct {
+	Version      string                  `json:"version"`
+	Download     string                  `json:"download_link"`
+	LastUpdated  string                  `json:"last_updated"`
+	Homepage     string                  `json:"homepage"`
+	Versions     utils.WordPressVersions `json:"versions"`
+	Requires     utils.WordPressString   `json:"requires"`
+	RequiresPHP  utils.WordPressString   `json:"requires_php"`
+	Tested       utils.WordPressString   `json:"tested"`
+	ReleaseDates map[string]string       `json:"release_dates"`
+	ChangelogUrl string                  `json:"changelog_url"`
+	Sections     struct {
+		Changelog string `json:"changelog"`
+	} `json:"sections"`
+}
+
+// GetReleaseDate uses release_dates, which is not part of the wordpress.org
+// API but provided by sources that know when each version was released.
+func (info PackageInfo) GetReleaseDate(version string) string {
+	if date, exists := info.ReleaseDates[version]; exists {
+		return date
+	}
+	if version == info.Version {
+		return info.LastUpdated
+	}
+	return ""
+}
+
+// Package is a plugin or theme installed in its own directory and updated
+// from a source. It is found by the NameHeader of its HeaderFile.
+type Package struct {
+	Kind       string
+	NameHeader string
+	HeaderFile string
+	Slug       string
+	Path       string
+	Name       string
+	Version    string
+	Header     utils.WordPressHeader
+	Info       PackageInfo
+	Target     string
+	Source     source.Source
+}
+
+// DiscoverPackages finds the plugins or themes of kind by the nameHeader of
+// the files matching pattern and loads their info from their source. loaded,
+// when given, fills in info the source does not provide.
+func DiscoverPackages(cnf *config.Config, kind string, pattern string, nameHeader string, loaded func(pkg *Package)) ([]interfaces.Resource, Report) {
+	return Discover(cnf, kind, pattern, nameHeader, func(slug string, path string, header utils.WordPressHeader) (interfaces.Resource, error) {
+		pkg := Package{Kind: kind, NameHeader: nameHeader, HeaderFile: filepath.Base(pattern), Slug: slug, Path: path, Name: header.Name, Version: header.Version, Header: header}
+		src, err := source.Get(cnf, kind, slug, header.UpdateUri)
+		if err != nil && cnf.GetSource(kind, slug).Type == "" {
+			// Resources routed away from wordpress.org by their Update URI
+			// are left alone until a source is configured
+			return nil, SkipError{Reason: err.Error()}
+		} else if err != nil {
+			return nil, err
+		}
+		pkg.Source = src
+		if err := src.Load(slug, &pkg.Info); err == utils.ErrNotFound {
+			return nil, SkipError{Reason: kind + " info not found"}
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to load %s info: %s", kind, err)
+		}
+		if loaded != nil {
+			loaded(&pkg)
+		}
+		pkg.Target = SelectVersion(cnf, kind, slug, header.Version, pkg.Info.Versions.List(pkg.Info.Version), pkg.Info.GetReleaseDate)
+		return pkg, nil
+	})
+}
+
+func (pkg Package) GetKind() string {
+	return pkg.Kind
+}
+
+func (pkg Package) GetSlug() string {
+	return pkg.Slug
+}
+
+func (pkg Package) GetName() string {
+	return pkg.Name
+}
+
+func (pkg Package) GetInstalledVersion() string {
+	return pkg.Version
+}
+
+func (pkg Package) GetAvailableVersion() string {
+	return pkg.Target
+}
+
+func (pkg Package) GetDownloadUrl() string {
+	if download, exists := pkg.Info.Versions[pkg.Target]; exists {
+		return download
+	}
+	return pkg.Info.Download
+}
+
+func (pkg Package) GetInstallDir() string {
+	return pkg.Path
+}
+
+func (pkg Package) GetHomePage() string {
+	return pkg.Info.Homepage
+}
+
+func (pkg Package) GetLastUpdated() string {
+	return pkg.Info.LastUpdated
+}
+
+func (pkg Package) GetReleaseDate() string {
+	return pkg.Info.GetReleaseDate(pkg.Target)
+}
+
+// GetChangelog only includes the notes of the versions after the installed
+// one up to the target.
+func (pkg Package) GetChangelog() string {
+	if pkg.Info.Sections.Changelog == "" {
+		return "Changelog information for this " + pkg.Kind + " is unavailable, please review the " + pkg.Kind + " homepage for further info."
+	}
+	link := pkg.Info.ChangelogUrl
+	if link == "" {
+		link = pkg.Info.Homepage
+	}
+	return changelog.Extract(pkg.Info.Sections.Changelog, pkg.Version, pkg.Target, link)
+}
+
+// GetRequirements is only known for the latest version, which is the version
+// the info API describes.
+func (pkg Package) GetRequirements() interfaces.Requirements {
+	if pkg.Target != pkg.Info.Version {
+		return interfaces.Requirements{Unknown: true}
+	}
+	return interfaces.Requirements{WordPress: string(pkg.Info.Requires), PHP: string(pkg.Info.RequiresPHP), Tested: string(pkg.Info.Tested)}
+}
+
+func (pkg Package) Download(url string, location string) error {
+	return pkg.Source.Download(url, location)
+}
+
+func (pkg Package) GetChecksums() (map[string][]string, error) {
+	if verifier, ok := pkg.Source.(source.Verifier); ok {
+		return verifier.GetChecksums(pkg.Slug, pkg.Target)
+	}
+	return nil, nil
+}
+
+// Validate checks the staged copy has a header of the version being installed.
+func (pkg Package) Validate(staged string) error {
+	matches, _ := filepath.Glob(filepath.Join(staged, pkg.HeaderFile))
+	for _, file := range matches {
+		header, err := utils.GetWordPressHeader(file, pkg.NameHeader)
+		if err != nil {
+			continue
+		}
+		if !utils.VersionCompare(header.Version, pkg.Target, "==") {
+			return fmt.Errorf("staged %s is version %s, expected %s", pkg.Kind, header.Version, pkg.Target)
+		}
+		return nil
+	}
+	return fmt.Errorf("staged %s has no %s header", pkg.Kind, pkg.NameHeader)
+}
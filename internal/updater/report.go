@@ -0,0 +1,67 @@
+package updater
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"strings"
+)
+
+const Succeeded = "succeeded"
+const Skipped = "skipped"
+const Failed = "failed"
+
+// SkipError is returned for resources that are deliberately not updated, so
+// they are reported as skipped rather than failed.
+type SkipError struct {
+	Reason string
+}
+
+func (err SkipError) Error() string {
+	return err.Reason
+}
+
+type Result struct {
+	Kind   string
+	Slug   string
+	Status string
+	Detail string
+}
+
+// Report collects the outcome of every resource in a run.
+type Report struct {
+	Results []Result
+}
+
+func (report *Report) Add(kind string, slug string, err error, detail string) {
+	result := Result{Kind: kind, Slug: slug, Status: Succeeded, Detail: detail}
+	if skip, ok := err.(SkipError); ok {
+		result.Status = Skipped
+		result.Detail = skip.Reason
+	} else if err != nil {
+		result.Status = Failed
+		result.Detail = err.Error()
+	}
+	report.Results = append(report.Results, result)
+}
+
+func (report *Report) Merge(other Report) {
+	report.Results = append(report.Results, other.Results...)
+}
+
+func (report Report) Count(status string) int {
+	count := 0
+	for _, result := range report.Results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+func (report Report) Print() {
+	logger.Println("")
+	logger.Printf("%-10v%-50v%-12v%v\n", "TYPE", "RESOURCE", "STATUS", "DETAIL")
+	for _, result := range report.Results {
+		logger.Printf("%-10v%-50v%-12v%v\n", result.Kind, result.Slug, result.Status, strings.TrimSpace(result.Detail))
+	}
+	logger.Printf("\n%d succeeded, %d skipped, %d failed\n", report.Count(Succeeded), report.Count(Skipped), report.Count(Failed))
+}
@@ -0,0 +1,93 @@
+package updater
+
+import (
+	"bytes"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"strings"
+)
+
+// TemplateData is passed to the commit, title and body templates defined in
+// the configured template file.
+type TemplateData struct {
+	Kind              string
+	Slug              string
+	Name              string
+	OldVersion        string
+	NewVersion        string
+	Bump              string
+	HomePage          string
+	LastUpdated       string
+	ReleaseDate       string
+	Changelog         string
+	Requirements      interfaces.Requirements
+	Incompatibilities []string
+	Warnings          []string
+	Verification      string
+	FilesChanged      int
+}
+
+func newTemplateData(cnf *config.Config, download Download) TemplateData {
+	r := download.Resource
+	problems, warnings := CheckCompatibility(cnf, r)
+	return TemplateData{
+		Kind:              r.GetKind(),
+		Slug:              r.GetSlug(),
+		Name:              r.GetName(),
+		OldVersion:        r.GetInstalledVersion(),
+		NewVersion:        r.GetAvailableVersion(),
+		Bump:              utils.VersionBump(r.GetInstalledVersion(), r.GetAvailableVersion()),
+		HomePage:          r.GetHomePage(),
+		LastUpdated:       r.GetLastUpdated(),
+		ReleaseDate:       r.GetReleaseDate(),
+		Changelog:         r.GetChangelog(),
+		Requirements:      r.GetRequirements(),
+		Incompatibilities: problems,
+		Warnings:          warnings,
+		Verification:      download.Summary,
+		FilesChanged:      download.FilesChanged,
+	}
+}
+
+// GroupTemplateData is passed to the group_title and group_body templates,
+// with the data of every update in the group.
+type GroupTemplateData struct {
+	Name    string
+	Updates []TemplateData
+}
+
+// renderTemplate executes the template called name, such as commit, title or
+// body, of the template file configured for the resource. It returns false
+// when no template file is configured or the file does not define name.
+func renderTemplate(cnf *config.Config, download Download, name string) (string, bool, error) {
+	return executeTemplate(cnf, download.Resource.GetKind(), name, func() interface{} {
+		return newTemplateData(cnf, download)
+	})
+}
+
+// renderGroupTemplate executes the group_title or group_body template of the
+// template file configured for the kind of the group, or the global one when
+// the group is not limited to a kind.
+func renderGroupTemplate(cnf *config.Config, group Group, downloads []Download, name string) (string, bool, error) {
+	return executeTemplate(cnf, group.Config.Kind, name, func() interface{} {
+		data := GroupTemplateData{Name: group.Config.Name, Updates: []TemplateData{}}
+		for _, download := range downloads {
+			data.Updates = append(data.Updates, newTemplateData(cnf, download))
+		}
+		return data
+	})
+}
+
+func executeTemplate(cnf *config.Config, kind string, name string, data func() interface{}) (string, bool, error) {
+	tmpl, err := cnf.GetTemplate(kind)
+	if err != nil || tmpl == nil || tmpl.Lookup(name) == nil {
+		return "", false, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&out, name, data()); err != nil {
+		return "", true, err
+	}
+	return strings.TrimSpace(out.String()), true, nil
+}
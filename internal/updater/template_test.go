@@ -0,0 +1,148 @@
+package updater
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTemplateConfig returns a config of a repository containing the template
+// files given by name.
+func newTemplateConfig(t *testing.T, files map[string]string) *config.Config {
+	dir, err := ioutil.TempDir("", "wpgitupdater-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &config.Config{Cwd: dir}
+}
+
+func newTemplateDownload(slug string, name string, version string, target string) Download {
+	return Download{
+		Resource:     Package{Kind: "plugin", Slug: slug, Name: name, Version: version, Target: target, Info: PackageInfo{Version: target, Homepage: "https://wordpress.org/plugins/" + slug + "/"}},
+		Summary:      "checksums verified",
+		FilesChanged: 3,
+	}
+}
+
+func TestGetPullRequestTemplate(t *testing.T) {
+	download := newTemplateDownload("akismet", "Akismet", "4.0", "5.0")
+	tests := []struct {
+		name    string
+		global  string
+		plugins string
+		title   string
+		body    string
+		commit  string
+	}{
+		{
+			name:   "defaults",
+			title:  "Update plugin akismet from 4.0 to 5.0",
+			commit: "chore(plugins): Update akismet from 4.0 to 5.0",
+		},
+		{
+			name:   "global template",
+			global: `{{define "title"}}Update {{.Name}} to {{.NewVersion}}{{if eq .Bump "major"}} (major){{end}}{{end}}{{define "body"}} {{.Verification}}, {{.FilesChanged}} files {{end}}{{define "commit"}}Bump {{.Slug}}{{end}}`,
+			title:  "Update Akismet to 5.0 (major)",
+			body:   "checksums verified, 3 files",
+			commit: "Bump akismet",
+		},
+		{
+			name:    "section template",
+			global:  `{{define "title"}}Global{{end}}`,
+			plugins: `{{define "title"}}Plugin {{.Slug}}{{end}}`,
+			title:   "Plugin akismet",
+			commit:  "chore(plugins): Update akismet from 4.0 to 5.0",
+		},
+		{
+			name:   "template without body",
+			global: `{{define "title"}}{{.Slug}} {{.OldVersion}}...{{.NewVersion}}{{end}}`,
+			title:  "akismet 4.0...5.0",
+			commit: "chore(plugins): Update akismet from 4.0 to 5.0",
+		},
+	}
+
+	for _, test := range tests {
+		cnf := newTemplateConfig(t, map[string]string{"global.tmpl": test.global, "plugins.tmpl": test.plugins})
+		if test.global != "" {
+			cnf.Template = "global.tmpl"
+		}
+		if test.plugins != "" {
+			cnf.Plugins.Template = "plugins.tmpl"
+		}
+
+		pr, err := GetPullRequest(cnf, download)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if pr.Title != test.title {
+			t.Errorf("%s: got title %q, want %q", test.name, pr.Title, test.title)
+		}
+		body := test.body
+		if body == "" {
+			body = GetPRBody(cnf, download.Resource, download.Summary)
+		}
+		if pr.Body != body {
+			t.Errorf("%s: got body %q, want %q", test.name, pr.Body, body)
+		}
+		commit, err := getCommitMessage(cnf, download)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if commit != test.commit {
+			t.Errorf("%s: got commit message %q, want %q", test.name, commit, test.commit)
+		}
+	}
+}
+
+func TestGetPullRequestTemplateError(t *testing.T) {
+	cnf := newTemplateConfig(t, map[string]string{"pr.tmpl": `{{define "title"}}{{.Missing}}{{end}}`})
+	cnf.Template = "pr.tmpl"
+	if _, err := GetPullRequest(cnf, newTemplateDownload("akismet", "Akismet", "4.0", "4.1")); err == nil {
+		t.Error("got no error executing a template with an unknown field")
+	}
+}
+
+func TestGroupGetPullRequestTemplate(t *testing.T) {
+	downloads := []Download{
+		newTemplateDownload("akismet", "Akismet", "4.0", "4.1"),
+		newTemplateDownload("hello", "Hello", "1.0", "1.0.1"),
+	}
+	group := Group{Config: config.GroupConfig{Name: "weekly"}, Resources: []interfaces.Resource{downloads[0].Resource, downloads[1].Resource}}
+
+	cnf := newTemplateConfig(t, map[string]string{"pr.tmpl": `{{define "group_title"}}{{.Name}}: {{len .Updates}} updates{{end}}{{define "group_body"}}{{range .Updates}}- {{.Name}} {{.Bump}}
+{{end}}{{end}}`})
+	cnf.Template = "pr.tmpl"
+	pr, err := group.GetPullRequest(cnf, downloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "weekly: 2 updates"; pr.Title != want {
+		t.Errorf("got title %q, want %q", pr.Title, want)
+	}
+	if want := "- Akismet minor\n- Hello patch"; pr.Body != want {
+		t.Errorf("got body %q, want %q", pr.Body, want)
+	}
+
+	cnf.Template = ""
+	pr, err = group.GetPullRequest(cnf, downloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Update 2 resources in group weekly"; pr.Title != want {
+		t.Errorf("got default title %q, want %q", pr.Title, want)
+	}
+	if want := group.GetPRBody(cnf); pr.Body != want {
+		t.Errorf("got default body %q, want %q", pr.Body, want)
+	}
+}
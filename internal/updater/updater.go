@@ -0,0 +1,326 @@
+package updater
+
+import (
+	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"github.com/wpgitupdater/wpgitupdater/internal/constants"
+	"github.com/wpgitupdater/wpgitupdater/internal/git"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
+	"github.com/wpgitupdater/wpgitupdater/internal/policy"
+	"github.com/wpgitupdater/wpgitupdater/internal/utils"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Discover walks the files matching pattern within the kinds configured path
+// and calls found for every resource directory that has a readable header,
+// reporting resources found returns an error for.
+func Discover(cnf *config.Config, kind string, pattern string, nameHeader string, found func(slug string, path string, header utils.WordPressHeader) (interfaces.Resource, error)) ([]interfaces.Resource, Report) {
+	resources := []interfaces.Resource{}
+	report := Report{}
+	seen := map[string]bool{}
+
+	logger.Printf("Collecting %s information\n", kind)
+	matches, _ := filepath.Glob(cnf.GetResourcePath(kind, pattern))
+	sort.Strings(matches)
+	for _, file := range matches {
+		path := filepath.Dir(file)
+		slug := filepath.Base(path)
+
+		if !cnf.CanBeUpdated(kind, slug) {
+			continue
+		}
+
+		if seen[slug] {
+			continue
+		}
+
+		header, err := utils.GetWordPressHeader(file, nameHeader)
+		if err != nil {
+			continue
+		}
+
+		seen[slug] = true
+		logger.Println(fmt.Sprintf("[%s] %s found", slug, kind))
+		logger.Println(fmt.Sprintf("[%s] loading external %s info", slug, kind))
+		r, err := found(slug, path, header)
+		if err != nil {
+			logger.Printf("[%s] %s, skipping\n", slug, err)
+			report.Add(kind, slug, err, "")
+			continue
+		}
+		resources = append(resources, r)
+	}
+
+	return resources, report
+}
+
+// SelectVersion picks the highest of the available versions permitted by the
+// configured update policy for the resource, preferring versions released
+// before the cool-down window. When every permitted version is still cooling
+// down the highest is returned and held back by GetHeldBackReason.
+func SelectVersion(cnf *config.Config, kind string, slug string, installed string, available []string, released func(version string) string) string {
+	rule := cnf.GetPolicy(kind, slug)
+	selected, err := policy.Select(rule, installed, available)
+	if err != nil {
+		logger.Printf("[%s] %s, skipping\n", slug, err)
+		return ""
+	}
+
+	if cnf.GetMinAge(kind) == 0 || released == nil {
+		return selected
+	}
+
+	settled := []string{}
+	for _, version := range available {
+		if _, cooling := getCoolDown(cnf, kind, released(version)); !cooling {
+			settled = append(settled, version)
+		}
+	}
+	if preferred, _ := policy.Select(rule, installed, settled); preferred != "" {
+		return preferred
+	}
+	return selected
+}
+
+// getCoolDown returns when a release leaves the cool-down window and whether
+// it is still within it. Releases without a known date are always held, with
+// a zero time.
+func getCoolDown(cnf *config.Config, kind string, released string) (time.Time, bool) {
+	minAge := cnf.GetMinAge(kind)
+	if minAge == 0 {
+		return time.Time{}, false
+	}
+	date, err := utils.ParseDate(released)
+	if released == "" || err != nil {
+		return time.Time{}, true
+	}
+	until := date.AddDate(0, 0, minAge)
+	return until, time.Now().Before(until)
+}
+
+func List(cnf *config.Config, resources []interfaces.Resource, report Report) {
+	for _, r := range resources {
+		logger.Printf("%-60v[%v]\n", r.GetSlug(), GetStatus(cnf, r))
+	}
+	for _, result := range report.Results {
+		logger.Printf("%-60v[%v (%v)]\n", result.Slug, result.Status, result.Detail)
+	}
+}
+
+// GetStatus describes whether a resource is up to date, can be updated, or is
+// held back along with the reason.
+func GetStatus(cnf *config.Config, r interfaces.Resource) string {
+	if !HasPendingUpdate(r) {
+		return "uptodate"
+	}
+	if reason := GetHeldBackReason(cnf, r); reason != "" {
+		return "held back (" + reason + ")"
+	}
+	if _, warnings := CheckCompatibility(cnf, r); len(warnings) > 0 {
+		return "outdated (" + strings.Join(warnings, ", ") + ")"
+	}
+	return "outdated"
+}
+
+// GetHeldBackReason explains why a pending update must not be applied, or
+// returns an empty string when it can be.
+func GetHeldBackReason(cnf *config.Config, r interfaces.Resource) string {
+	if until, cooling := getCoolDown(cnf, r.GetKind(), r.GetReleaseDate()); cooling && until.IsZero() {
+		return "cool-down, release date unknown"
+	} else if cooling {
+		return "cool-down until " + until.Format("2006-01-02")
+	}
+	problems, _ := CheckCompatibility(cnf, r)
+	if len(problems) > 0 && cnf.BlocksIncompatible() {
+		return "incompatible, " + strings.Join(problems, ", ")
+	}
+	return ""
+}
+
+// Update performs every pending update, continuing past failures, and
+// reports the outcome for each resource.
+func Update(cnf *config.Config, resources []interfaces.Resource, dryRun bool, stats bool) Report {
+	report := Report{}
+	groups := map[string]*Group{}
+	names := []string{}
+	for _, r := range resources {
+		if !HasPendingUpdate(r) {
+			logger.Printf("[%s] Already up to date, skipping\n", r.GetSlug())
+			report.Add(r.GetKind(), r.GetSlug(), SkipError{"up to date"}, "")
+			continue
+		}
+
+		if reason := GetHeldBackReason(cnf, r); reason != "" {
+			logger.Printf("[%s] Held back (%s), skipping\n", r.GetSlug(), reason)
+			report.Add(r.GetKind(), r.GetSlug(), SkipError{"held back, " + reason}, "")
+			continue
+		}
+
+		groupConfig, found := cnf.GetGroup(r.GetKind(), r.GetSlug(), utils.VersionBump(r.GetInstalledVersion(), r.GetAvailableVersion()))
+		if !found {
+			err := PerformUpdate(cnf, r, dryRun, stats)
+			if err != nil {
+				logger.Printf("[%s] %s\n", r.GetSlug(), err)
+			}
+			report.Add(r.GetKind(), r.GetSlug(), err, r.GetInstalledVersion()+" to "+r.GetAvailableVersion())
+			continue
+		}
+
+		if _, exists := groups[groupConfig.Name]; !exists {
+			groups[groupConfig.Name] = &Group{Config: groupConfig}
+			names = append(names, groupConfig.Name)
+		}
+		groups[groupConfig.Name].Resources = append(groups[groupConfig.Name].Resources, r)
+	}
+
+	for _, name := range names {
+		group := groups[name]
+		err := group.PerformUpdate(cnf, dryRun, stats)
+		if err != nil {
+			logger.Printf("[group %s] %s\n", name, err)
+		}
+		for _, r := range group.Resources {
+			report.Add(r.GetKind(), r.GetSlug(), err, r.GetInstalledVersion()+" to "+r.GetAvailableVersion()+" in group "+name)
+		}
+	}
+
+	return report
+}
+
+func HasPendingUpdate(r interfaces.Resource) bool {
+	if r.GetAvailableVersion() == "" {
+		return false
+	}
+	return utils.VersionCompare(r.GetInstalledVersion(), r.GetAvailableVersion(), "<")
+}
+
+// versionsR matches the installed and available versions ending branch names.
+var versionsR = regexp.MustCompile(`^\d[^-]*-\d[^-]*$`)
+
+func GetBranchName(r interfaces.Resource) string {
+	return "wpgitupdates-" + r.GetKind() + "-" + r.GetSlug() + "-" + r.GetInstalledVersion() + "-" + r.GetAvailableVersion()
+}
+
+func replacePlaceholders(template string, r interfaces.Resource) string {
+	msg := strings.ReplaceAll(template, ":"+r.GetKind(), r.GetSlug())
+	msg = strings.ReplaceAll(msg, ":oldversion", r.GetInstalledVersion())
+	msg = strings.ReplaceAll(msg, ":newversion", r.GetAvailableVersion())
+	return msg
+}
+
+func GetCommitMessage(cnf *config.Config, r interfaces.Resource) string {
+	return replacePlaceholders(cnf.GetCommit(r.GetKind(), r.GetSlug()), r)
+}
+
+// getCommitMessage uses the commit template of the configured template file
+// when defined.
+func getCommitMessage(cnf *config.Config, download Download) (string, error) {
+	message, found, err := renderTemplate(cnf, download, "commit")
+	if err != nil || found {
+		return message, err
+	}
+	return GetCommitMessage(cnf, download.Resource), nil
+}
+
+func GetPRTitle(cnf *config.Config, r interfaces.Resource) string {
+	return replacePlaceholders(cnf.GetPRTitle(r.GetKind(), r.GetSlug()), r)
+}
+
+func getPRHeader() string {
+	return `**Update Generated By:** WordPress Git Updater V` + constants.Version + `
+**Build:** ` + constants.Build + `
+**Build Date:** ` + constants.BuildDate
+}
+
+func getPRDetails(cnf *config.Config, r interfaces.Resource, verification string) string {
+	return `**Homepage:** ` + r.GetHomePage() + `
+**Updated:** ` + r.GetLastUpdated() + `
+**Verification:** ` + verification + `
+
+` + getPRCompatibility(cnf, r) + `**Changelog:**
+
+` + r.GetChangelog()
+}
+
+func GetPRBody(cnf *config.Config, r interfaces.Resource, verification string) string {
+	return getPRHeader() + "\n\n" + getPRDetails(cnf, r, verification)
+}
+
+func UpdateBranchExists(r interfaces.Resource) bool {
+	return git.BranchExists(GetBranchName(r))
+}
+
+func PerformUpdate(cnf *config.Config, r interfaces.Resource, dryRun bool, stats bool) error {
+	slug := r.GetSlug()
+
+	if !HasPendingUpdate(r) {
+		logger.Printf("[%s] Already up to date, skipping\n", slug)
+		return SkipError{"up to date"}
+	}
+
+	if UpdateBranchExists(r) {
+		logger.Printf("[%s] Update branch exists, skipping\n", slug)
+		return SkipError{"update branch exists"}
+	}
+
+	if dryRun {
+		logger.Printf("[%s] Skipping actual update process...\n", slug)
+		return SkipError{"dry run, " + r.GetInstalledVersion() + " to " + r.GetAvailableVersion()}
+	}
+
+	cs := changeSet{
+		Name:      slug,
+		Branch:    GetBranchName(r),
+		Prefix:    "wpgitupdates-" + r.GetKind() + "-" + slug + "-",
+		Pattern:   versionsR,
+		Resources: []interfaces.Resource{r},
+		PullRequest: func(downloads []Download) (interfaces.PullRequest, error) {
+			return GetPullRequest(cnf, downloads[0])
+		},
+	}
+	return cs.publish(cnf, stats)
+}
+
+// GetPullRequest uses the title and body templates of the configured
+// template file when defined.
+func GetPullRequest(cnf *config.Config, download Download) (interfaces.PullRequest, error) {
+	r := download.Resource
+	title, found, err := renderTemplate(cnf, download, "title")
+	if err != nil {
+		return interfaces.PullRequest{}, err
+	} else if !found {
+		title = GetPRTitle(cnf, r)
+	}
+	body, found, err := renderTemplate(cnf, download, "body")
+	if err != nil {
+		return interfaces.PullRequest{}, err
+	} else if !found {
+		body = GetPRBody(cnf, r, download.Summary)
+	}
+
+	bump := getBump(cnf, r)
+	return interfaces.PullRequest{
+		Title:  title,
+		Head:   GetBranchName(r),
+		Base:   cnf.GetBranch(r.GetKind(), r.GetSlug()),
+		Body:   body,
+		Labels: cnf.GetLabels(r.GetKind(), r.GetSlug()),
+		Draft:  bump.Draft,
+
+		Reviewers:     cnf.GetReviewers(r.GetKind()),
+		TeamReviewers: cnf.GetTeamReviewers(r.GetKind()),
+		Assignees:     cnf.GetAssignees(r.GetKind()),
+		Milestone:     cnf.GetMilestone(r.GetKind()),
+		AutoMerge:     bump.Automerge,
+	}, nil
+}
+
+// getBump returns the pull request options for the bump level of the update.
+func getBump(cnf *config.Config, r interfaces.Resource) config.BumpConfig {
+	return cnf.GetBump(r.GetKind(), r.GetSlug(), utils.VersionBump(r.GetInstalledVersion(), r.GetAvailableVersion()))
+}
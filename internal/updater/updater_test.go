@@ -0,0 +1,70 @@
+package updater
+
+import (
+	"github.com/wpgitupdater/wpgitupdater/internal/config"
+	"testing"
+	"time"
+)
+
+// daysAgo formats the date the given number of days before today.
+func daysAgo(days int) string {
+	return time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+}
+
+func TestSelectVersion(t *testing.T) {
+	available := []string{"4.0", "4.0.1", "4.1", "5.0", "5.1-beta1"}
+	dates := map[string]string{"4.0.1": daysAgo(30), "4.1": daysAgo(10), "5.0": daysAgo(2)}
+	released := func(version string) string {
+		return dates[version]
+	}
+	tests := []struct {
+		name     string
+		policy   string
+		minAge   int
+		released func(version string) string
+		want     string
+	}{
+		{"latest stable", "", 0, released, "5.0"},
+		{"policy", "minor", 0, released, "4.1"},
+		{"patch policy", "patch", 0, released, "4.0.1"},
+		{"invalid policy", "latest", 0, released, ""},
+		{"settled release preferred", "", 7, released, "4.1"},
+		{"settled release within policy", "patch", 7, released, "4.0.1"},
+		{"every release cooling", "", 60, released, "5.0"},
+		{"release dates unknown", "", 7, nil, "5.0"},
+	}
+
+	for _, test := range tests {
+		cnf := &config.Config{Policy: test.policy}
+		cnf.Plugins.MinAge = test.minAge
+		got := SelectVersion(cnf, "plugin", "akismet", "4.0", available, test.released)
+		if got != test.want {
+			t.Errorf("%s: SelectVersion = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGetHeldBackReason(t *testing.T) {
+	until := time.Now().AddDate(0, 0, 5).Format("2006-01-02")
+	tests := []struct {
+		name     string
+		minAge   int
+		released string
+		want     string
+	}{
+		{"no cool-down", 0, "", ""},
+		{"cooling", 7, daysAgo(2), "cool-down until " + until},
+		{"settled", 7, daysAgo(10), ""},
+		{"release date unknown", 7, "", "cool-down, release date unknown"},
+		{"release date invalid", 7, "yesterday", "cool-down, release date unknown"},
+	}
+
+	for _, test := range tests {
+		cnf := &config.Config{}
+		cnf.Plugins.MinAge = test.minAge
+		pkg := Package{Kind: "plugin", Slug: "akismet", Version: "4.0", Target: "4.1", Info: PackageInfo{Version: "4.2", ReleaseDates: map[string]string{"4.1": test.released}}}
+		if got := GetHeldBackReason(cnf, pkg); got != test.want {
+			t.Errorf("%s: GetHeldBackReason = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
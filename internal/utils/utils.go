@@ -2,12 +2,15 @@ package utils
 
 import (
 	"archive/zip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
 	"os/exec"
@@ -15,43 +18,67 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
-func GetCwd() string {
-	cwd, err := os.Getwd()
-	if err != nil {
-		log.Fatal(err)
-	}
+// ErrNotFound is returned when a remote resource does not exist.
+var ErrNotFound = errors.New("not found")
 
-	return cwd
+func GetCwd() (string, error) {
+	return os.Getwd()
 }
 
-func GetToken() string {
+func GetToken() (string, error) {
 	token := os.Getenv("WP_GIT_UPDATER_GIT_TOKEN")
 	if token == "" {
-		log.Fatal("Missing WP_GIT_UPDATER_GIT_TOKEN variable!")
+		return "", errors.New("Missing WP_GIT_UPDATER_GIT_TOKEN variable!")
 	}
-	return token
+	logger.AddSecret(token)
+	// Tokens may be username:password pairs, where only the password is secret
+	if i := strings.Index(token, ":"); i >= 0 {
+		logger.AddSecret(token[i+1:])
+	}
+	return token, nil
 }
 
-func GetUpdaterToken() string {
+func GetUpdaterToken() (string, error) {
 	token := os.Getenv("WP_GIT_UPDATER_TOKEN")
 	if token == "" {
-		log.Fatal("Missing WP_GIT_UPDATER_TOKEN variable!")
+		return "", errors.New("Missing WP_GIT_UPDATER_TOKEN variable!")
+	}
+	logger.AddSecret(token)
+	return token, nil
+}
+
+// GetGitHubToken returns the optional token releases on github.com are read
+// with, for sites whose repository is hosted elsewhere.
+func GetGitHubToken() string {
+	token := os.Getenv("WP_GIT_UPDATER_GITHUB_TOKEN")
+	if token != "" {
+		logger.AddSecret(token)
 	}
 	return token
 }
 
-func RunCmd(parts ...string) string {
-	fmt.Println("Command: " + strings.Join(parts, " "))
+// RunCmd runs a command in the working directory, returning its combined
+// output and an error including that output when the command fails.
+func RunCmd(parts ...string) (string, error) {
+	return RunCmdEnv(nil, parts...)
+}
+
+// RunCmdEnv runs a command like RunCmd with env added to its environment.
+func RunCmdEnv(env []string, parts ...string) (string, error) {
+	logger.Println("Command: " + strings.Join(parts, " "))
 	cmd := exec.Command(parts[0], parts[1:]...)
-	cmd.Dir = GetCwd()
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Fatalf("cmd failed with %s (%s)\n", err, cmd.Stderr)
+		return string(output), logger.Error(fmt.Errorf("%s failed with %s (%s)", parts[0], err, strings.TrimSpace(string(output))))
 	}
 
-	return string(output)
+	return string(output), nil
 }
 
 // @see https://github.com/syyongx/php2go/blob/master/php.go#L1976
@@ -308,6 +335,17 @@ func Unzip(src string, dest string) ([]string, error) {
 	return filenames, nil
 }
 
+// InPaths reports whether the slash separated path is one of paths or within
+// one of them.
+func InPaths(paths []string, path string) bool {
+	for _, parent := range paths {
+		if path == parent || strings.HasPrefix(path, parent+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 func InSlice(slice []string, val string) (int, bool) {
 	for i, item := range slice {
 		if item == val {
@@ -318,56 +356,431 @@ func InSlice(slice []string, val string) (int, bool) {
 }
 
 func DownloadUrl(url string, location string) error {
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
+	var body io.ReadCloser
+	if strings.HasPrefix(url, "file://") {
+		in, err := os.Open(strings.TrimPrefix(url, "file://"))
+		if err != nil {
+			return err
+		}
+		body = in
+	} else {
+		resp, err := http.Get(url)
+		if err != nil {
+			return logger.Error(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return logger.Error(fmt.Errorf("%s: unexpected status %d", url, resp.StatusCode))
+		}
+		body = resp.Body
 	}
-	defer resp.Body.Close()
+	defer body.Close()
 	out, err := os.Create(location)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
-	_, err = io.Copy(out, resp.Body)
+	_, err = io.Copy(out, body)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func GetWordPressHeaderInfo(file string, nameMatch string, versionMatch string) (string, string, error) {
+// WordPressHeader holds the file header fields WordPress reads from a plugin
+// main file or theme stylesheet.
+type WordPressHeader struct {
+	Name        string
+	Version     string
+	UpdateUri   string
+	RequiresPHP string
+	RequiresWP  string
+	TextDomain  string
+	Author      string
+}
+
+// GetWordPressHeader parses the header block of file, nameMatch being the
+// field identifying the resource type e.g. "Plugin Name" or "Theme Name".
+func GetWordPressHeader(file string, nameMatch string) (WordPressHeader, error) {
 	content, err := ioutil.ReadFile(file)
 	if err != nil {
-		return "", "", err
+		return WordPressHeader{}, err
 	}
 
+	// WordPress only reads the first 8KB of a file for headers
 	text := string(content)
+	if len(text) > 8192 {
+		text = text[:8192]
+	}
 
-	nameR, _ := regexp.Compile("[ \t/*#@]*" + nameMatch + ":(?P<Name>.*)")
-	name := nameR.FindStringSubmatch(text)
-	if len(name) < 1 {
-		return "", "", errors.New("")
+	field := func(match string) string {
+		r := regexp.MustCompile(`(?mi)^(?:[ \t]*<\?php)?[ \t/*#@]*` + regexp.QuoteMeta(match) + `:(.*)$`)
+		value := r.FindStringSubmatch(text)
+		if len(value) < 2 {
+			return ""
+		}
+		return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value[1]), "*/"))
 	}
 
-	versionR, _ := regexp.Compile("[ \t/*#@]*" + versionMatch + ":(?P<Version>.*)")
-	version := versionR.FindStringSubmatch(text)
-	if len(version) < 1 {
-		return "", "", errors.New("")
+	header := WordPressHeader{
+		Name:        field(nameMatch),
+		Version:     field("Version"),
+		UpdateUri:   field("Update URI"),
+		RequiresPHP: field("Requires PHP"),
+		RequiresWP:  field("Requires at least"),
+		TextDomain:  field("Text Domain"),
+		Author:      field("Author"),
+	}
+	if header.Name == "" || header.Version == "" {
+		return header, fmt.Errorf("%s: missing %s or Version header", file, nameMatch)
 	}
 
-	return strings.TrimSpace(name[1]), strings.TrimSpace(version[1]), nil
+	return header, nil
 }
 
-func LoadWordPressApiInfo(url string, info interface{}) {
+func LoadWordPressApiInfo(url string, info interface{}) error {
 	resp, err := http.Get(url)
 	if err != nil {
-		log.Fatal(err)
+		return logger.Error(err)
 	}
 
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return logger.Error(fmt.Errorf("%s: unexpected status %d", url, resp.StatusCode))
+	}
+
 	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(&info)
+	return decoder.Decode(&info)
+}
+
+func GetWordPressVersion(file string) (string, error) {
+	content, err := ioutil.ReadFile(file)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
+	}
+
+	versionR := regexp.MustCompile(`\$wp_version\s*=\s*['"]([^'"]+)['"]`)
+	version := versionR.FindStringSubmatch(string(content))
+	if len(version) < 2 {
+		return "", fmt.Errorf("%s: unable to find $wp_version", file)
+	}
+
+	return strings.TrimSpace(version[1]), nil
+}
+
+func CopyDir(src string, dest string, skip []string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		if _, skipped := InSlice(skip, filepath.ToSlash(rel)); skipped {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
+
+// VersionBump reports whether moving from version1 to version2 is a "major",
+// "minor" or "patch" level change, based on the first differing segment.
+func VersionBump(version1 string, version2 string) string {
+	parts1 := strings.Split(version1, ".")
+	parts2 := strings.Split(version2, ".")
+	for i := 0; i < len(parts1) || i < len(parts2); i++ {
+		part1, part2 := "0", "0"
+		if i < len(parts1) {
+			part1 = parts1[i]
+		}
+		if i < len(parts2) {
+			part2 = parts2[i]
+		}
+		if part1 == part2 {
+			continue
+		}
+		switch i {
+		case 0:
+			return "major"
+		case 1:
+			return "minor"
+		default:
+			return "patch"
+		}
+	}
+	return "patch"
+}
+
+// WordPressVersions maps released versions to their download links, the
+// wordpress.org API returns an empty array rather than an object when a
+// resource has no tagged versions.
+type WordPressVersions map[string]string
+
+func (versions *WordPressVersions) UnmarshalJSON(data []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		*versions = WordPressVersions{}
+		return nil
+	}
+	decoded := map[string]string{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*versions = decoded
+	return nil
+}
+
+// List returns the tagged versions up to and including latest, the stable
+// tag, as tags above it have not been released yet.
+func (versions WordPressVersions) List(latest string) []string {
+	list := []string{}
+	if latest != "" {
+		list = append(list, latest)
+	}
+	for version := range versions {
+		if version != "trunk" && version != latest && (latest == "" || VersionCompare(version, latest, "<")) {
+			list = append(list, version)
+		}
+	}
+	return list
+}
+
+// preReleaseR matches pre-release suffixes, such as "-beta.1", "RC2" or
+// "-dev", following a version number.
+var preReleaseR = regexp.MustCompile(`(?i)[\d.\-_](?:alpha|beta|rc|dev|pre)[.\d]*$`)
+
+// IsPreRelease reports whether version is a development, alpha, beta or
+// release candidate version, such as "8.0.0-beta.1" or "6.1RC2".
+func IsPreRelease(version string) bool {
+	return preReleaseR.MatchString(version)
+}
+
+// NormaliseZip rewrites an archive so everything is extracted into a single
+// top level directory named dir, renaming directories like GitHub zipball
+// "owner-repo-sha" roots and wrapping archives without a root directory.
+func NormaliseZip(src string, dir string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	roots := map[string]bool{}
+	for _, f := range r.File {
+		if strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		parts := strings.SplitN(f.Name, "/", 2)
+		if len(parts) == 1 {
+			roots[""] = true
+			continue
+		}
+		roots[parts[0]] = true
+	}
+
+	root := ""
+	if len(roots) == 1 {
+		for name := range roots {
+			root = name
+		}
+	}
+	if root == dir {
+		return nil
+	}
+
+	tmp := src + ".normalised"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := zip.NewWriter(out)
+	for _, f := range r.File {
+		if strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+
+		name := dir + "/" + f.Name
+		if root != "" {
+			name = dir + strings.TrimPrefix(f.Name, root)
+		}
+
+		header := &zip.FileHeader{Name: name, Method: f.Method, Modified: f.Modified}
+		header.SetMode(f.Mode())
+		writer, err := w.CreateHeader(header)
+		if err != nil {
+			out.Close()
+			return err
+		}
+
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			out.Close()
+			return err
+		}
+		_, err = io.Copy(writer, rc)
+		rc.Close()
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	r.Close()
+	return os.Rename(tmp, src)
+}
+
+// WordPressString is a string field the wordpress.org API reports as false
+// when unset, such as the requires and requires_php plugin fields.
+type WordPressString string
+
+func (value *WordPressString) UnmarshalJSON(data []byte) error {
+	decoded := ""
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		*value = ""
+		return nil
+	}
+	*value = WordPressString(decoded)
+	return nil
+}
+
+// ParseDate reads the date formats used by the wordpress.org API, e.g.
+// "2021-07-20 4:31pm GMT", and RFC 3339 dates used by the GitHub API.
+func ParseDate(value string) (time.Time, error) {
+	layouts := []string{"2006-01-02 3:04pm MST", time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+	for _, layout := range layouts {
+		if parsed, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse date [%s]", value)
+}
+
+// ChecksumMatches reports whether content hashes to any of the md5 or sha256
+// hex digests given.
+func ChecksumMatches(content io.Reader, digests []string) (bool, error) {
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), content); err != nil {
+		return false, err
+	}
+	md5Sum := hex.EncodeToString(md5Hash.Sum(nil))
+	sha256Sum := hex.EncodeToString(sha256Hash.Sum(nil))
+	for _, digest := range digests {
+		if strings.EqualFold(digest, md5Sum) || strings.EqualFold(digest, sha256Sum) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// VerifyZip checks every file in an archive, relative to its top level
+// directory, against checksums. Files without a published checksum fail
+// verification, unless within one of the skipped paths.
+func VerifyZip(src string, checksums map[string][]string, skip []string) (int, error) {
+	verified := 0
+
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return verified, err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+
+		parts := strings.SplitN(f.Name, "/", 2)
+		name := parts[len(parts)-1]
+		if InPaths(skip, name) {
+			continue
+		}
+		digests, exists := checksums[name]
+		if !exists {
+			return verified, fmt.Errorf("%s: no published checksum", name)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return verified, err
+		}
+		matches, err := ChecksumMatches(rc, digests)
+		rc.Close()
+		if err != nil {
+			return verified, err
+		}
+		if !matches {
+			return verified, fmt.Errorf("%s: checksum mismatch", name)
+		}
+		verified++
+	}
+
+	return verified, nil
+}
+
+// VerifyFiles checks every file listed in checksums exists within root and
+// matches its checksum.
+func VerifyFiles(root string, checksums map[string][]string) (int, error) {
+	verified := 0
+	for name, digests := range checksums {
+		file, err := os.Open(filepath.Join(root, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			return verified, fmt.Errorf("%s: missing", name)
+		} else if err != nil {
+			return verified, err
+		}
+		matches, err := ChecksumMatches(file, digests)
+		file.Close()
+		if err != nil {
+			return verified, err
+		}
+		if !matches {
+			return verified, fmt.Errorf("%s: checksum mismatch", name)
+		}
+		verified++
 	}
+	return verified, nil
 }
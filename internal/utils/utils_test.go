@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// md5 digests of the contents written by writeZip.
+const helloMd5 = "5d41402abc4b2a76b9719d911017c592"
+const worldMd5 = "7d793037a0760186574b0282f2f435e7"
+
+// writeZip creates an archive of files, directories being names ending in a
+// slash.
+func writeZip(t *testing.T, dir string, files map[string]string) string {
+	src := filepath.Join(dir, "archive.zip")
+	out, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := zip.NewWriter(out)
+	for _, name := range names {
+		writer, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+// readZip returns the names and contents of the files in an archive.
+func readZip(t *testing.T, src string) map[string]string {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	files := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestNormaliseZip(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  map[string]string
+	}{
+		{
+			name:  "github zipball root",
+			files: map[string]string{"owner-akismet-1a2b3c/": "", "owner-akismet-1a2b3c/akismet.php": "hello", "owner-akismet-1a2b3c/inc/a.php": "world"},
+			want:  map[string]string{"akismet/": "", "akismet/akismet.php": "hello", "akismet/inc/a.php": "world"},
+		},
+		{
+			name:  "no root",
+			files: map[string]string{"akismet.php": "hello", "inc/a.php": "world"},
+			want:  map[string]string{"akismet/akismet.php": "hello", "akismet/inc/a.php": "world"},
+		},
+		{
+			name:  "macos metadata",
+			files: map[string]string{"akismet-main/akismet.php": "hello", "__MACOSX/akismet-main/._akismet.php": "x"},
+			want:  map[string]string{"akismet/akismet.php": "hello"},
+		},
+		{
+			name:  "already normalised",
+			files: map[string]string{"akismet/akismet.php": "hello"},
+			want:  map[string]string{"akismet/akismet.php": "hello"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "wpgitupdater-test-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			src := writeZip(t, dir, test.files)
+			if err := NormaliseZip(src, "akismet"); err != nil {
+				t.Fatal(err)
+			}
+			if got := readZip(t, src); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyZip(t *testing.T) {
+	files := map[string]string{"akismet/": "", "akismet/akismet.php": "hello", "akismet/inc/a.php": "world"}
+	tests := []struct {
+		name      string
+		checksums map[string][]string
+		skip      []string
+		verified  int
+		err       string
+	}{
+		{
+			name:      "verified",
+			checksums: map[string][]string{"akismet.php": {helloMd5}, "inc/a.php": {"bad", worldMd5}},
+			verified:  2,
+		},
+		{
+			name:      "mismatch",
+			checksums: map[string][]string{"akismet.php": {worldMd5}, "inc/a.php": {worldMd5}},
+			err:       "akismet.php: checksum mismatch",
+		},
+		{
+			name:      "missing checksum",
+			checksums: map[string][]string{"akismet.php": {helloMd5}},
+			verified:  1,
+			err:       "inc/a.php: no published checksum",
+		},
+		{
+			name:      "skipped",
+			checksums: map[string][]string{"akismet.php": {helloMd5}},
+			skip:      []string{"inc"},
+			verified:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "wpgitupdater-test-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			verified, err := VerifyZip(writeZip(t, dir, files), test.checksums, test.skip)
+			if verified != test.verified {
+				t.Errorf("verified %d files, want %d", verified, test.verified)
+			}
+			if test.err == "" && err != nil {
+				t.Errorf("got error %s", err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestVerifyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wpgitupdater-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "akismet.php"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if verified, err := VerifyFiles(dir, map[string][]string{"akismet.php": {helloMd5}}); err != nil || verified != 1 {
+		t.Errorf("got %d verified and error %v, want 1 verified", verified, err)
+	}
+	if _, err := VerifyFiles(dir, map[string][]string{"akismet.php": {worldMd5}}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("got error %v, want checksum mismatch", err)
+	}
+	if _, err := VerifyFiles(dir, map[string][]string{"inc/a.php": {worldMd5}}); err == nil || err.Error() != "inc/a.php: missing" {
+		t.Errorf("got error %v, want inc/a.php: missing", err)
+	}
+}
+
+func TestWordPressVersionsList(t *testing.T) {
+	versions := WordPressVersions{"trunk": "", "4.0": "", "4.1": "", "4.2-beta1": "", "5.0": ""}
+	got := versions.List("4.1")
+	sort.Strings(got)
+	if want := []string{"4.0", "4.1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIsPreRelease(t *testing.T) {
+	tests := map[string]bool{
+		"5.0":          false,
+		"5.0.1":        false,
+		"2.1-1":        false,
+		"1.0.0-2021":   false,
+		"3.4-premium":  false,
+		"1.2-develop3": false,
+		"8.0.0-beta.1": true,
+		"6.1RC2":       true,
+		"2.0alpha":     true,
+		"1.0-dev":      true,
+		"5.9-pre":      true,
+		"4.0.rc.1":     true,
+	}
+	for version, want := range tests {
+		if got := IsPreRelease(version); got != want {
+			t.Errorf("IsPreRelease(%q) = %t, want %t", version, got, want)
+		}
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"github.com/wpgitupdater/wpgitupdater/internal/config"
 	"github.com/wpgitupdater/wpgitupdater/internal/constants"
+	"github.com/wpgitupdater/wpgitupdater/internal/core"
 	"github.com/wpgitupdater/wpgitupdater/internal/git"
 	"github.com/wpgitupdater/wpgitupdater/internal/github"
+	"github.com/wpgitupdater/wpgitupdater/internal/interfaces"
+	"github.com/wpgitupdater/wpgitupdater/internal/logger"
 	"github.com/wpgitupdater/wpgitupdater/internal/plugin"
 	"github.com/wpgitupdater/wpgitupdater/internal/theme"
+	"github.com/wpgitupdater/wpgitupdater/internal/updater"
 	"log"
 	"os"
 	"strings"
@@ -16,15 +20,16 @@ import (
 
 func main() {
 
-	fmt.Println("WordPress Git Updater v" + constants.Version)
-	fmt.Println("Build:", constants.Build)
-	fmt.Println("Build Date:", constants.BuildDate)
+	logger.Println("WordPress Git Updater v" + constants.Version)
+	logger.Println("Build:", constants.Build)
+	logger.Println("Build Date:", constants.BuildDate)
 
-	var commands map[string]func()
-	commands = make(map[string]func())
+	var commands map[string]func() error
+	commands = make(map[string]func() error)
 	commands["init"] = InitCommand()
 	commands["list"] = ListCommand()
 	commands["update"] = UpdateCommand()
+	commands["cleanup"] = CleanupCommand()
 
 	keys := make([]string, 0, len(commands))
 	for k := range commands {
@@ -41,11 +46,13 @@ func main() {
 		log.Fatal("Expected one of ", commandNames, " command")
 	}
 
-	commands[commandName]()
+	if err := commands[commandName](); err != nil {
+		log.Fatal(logger.Redact(err.Error()))
+	}
 }
 
-func InitCommand() func() {
-	return func() {
+func InitCommand() func() error {
+	return func() error {
 		cmd := flag.NewFlagSet("init", flag.ExitOnError)
 		var ci bool
 		cmd.BoolVar(&ci, "ci", false, "Create a CI config file")
@@ -54,70 +61,159 @@ func InitCommand() func() {
 		cmd.Parse(os.Args[2:])
 
 		if ci && actions {
-			fmt.Println("Creating workflow file")
-			github.CreateWorkflowTemplate()
-			fmt.Println("Workflow file created!")
+			logger.Println("Creating workflow file")
+			if err := github.CreateWorkflowTemplate(); err != nil {
+				return err
+			}
+			logger.Println("Workflow file created!")
 		} else {
-			fmt.Println("Creating config file")
-			config.CreateConfigTemplate()
-			fmt.Println("Config file created!")
+			logger.Println("Creating config file")
+			if err := config.CreateConfigTemplate(); err != nil {
+				return err
+			}
+			logger.Println("Config file created!")
 		}
+		return nil
 	}
 }
 
-func ListCommand() func() {
-	return func() {
+func ListCommand() func() error {
+	return func() error {
 		cmd := flag.NewFlagSet("list", flag.ExitOnError)
 		var plugins bool
 		cmd.BoolVar(&plugins, "plugins", true, "List plugin updates")
 		var themes bool
 		cmd.BoolVar(&themes, "themes", true, "List theme updates")
+		var wordpress bool
+		cmd.BoolVar(&wordpress, "core", true, "List core updates")
 		cmd.Parse(os.Args[2:])
-		fmt.Println("List update statuses")
+		logger.Println("List update statuses")
 
-		cnf := config.LoadConfig()
+		cnf, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
 		if plugins {
 			plugin.ListPlugins(&cnf)
 		} else {
-			fmt.Println("Skipping plugins")
+			logger.Println("Skipping plugins")
 		}
 		if themes {
 			theme.ListThemes(&cnf)
 		} else {
-			fmt.Println("Skipping themes")
+			logger.Println("Skipping themes")
+		}
+		if wordpress && cnf.Core.Enabled {
+			core.ListCore(&cnf)
+		} else {
+			logger.Println("Skipping core")
 		}
+		return nil
 	}
 }
 
-func UpdateCommand() func() {
-	return func() {
+func UpdateCommand() func() error {
+	return func() error {
 		cmd := flag.NewFlagSet("update", flag.ExitOnError)
 		var dryRun bool
 		var stats bool
 		cmd.BoolVar(&dryRun, "dry-run", false, "Perform an update dry run, this stops short of creating an update branches")
 		cmd.BoolVar(&stats, "stats", true, "Login plugin, provider and repository names in your usage statistics")
 		cmd.Parse(os.Args[2:])
-		fmt.Println("Performing updates")
+		logger.Println("Performing updates")
 
-		cnf := config.LoadConfig()
+		cnf, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
 
 		if dryRun == false {
-			git.ConfigureGitConfig(&cnf)
-			defer git.RestoreGitConfig(&cnf)
+			restore, err := git.Configure(&cnf)
+			defer func() {
+				if err := restore(); err != nil {
+					logger.Println(err)
+				}
+			}()
+			if err != nil {
+				return err
+			}
 		}
 
-		if cnf.Plugins.Enabled {
-			fmt.Println("Performing plugin updates")
-			plugin.UpdatePlugins(&cnf, dryRun, stats)
-		} else {
-			fmt.Println("Plugin updates disabled")
+		resources, report := collectResources(&cnf)
+		report.Merge(updater.Update(&cnf, resources, dryRun, stats))
+		report.Print()
+
+		if failed := report.Count(updater.Failed); failed > 0 {
+			return fmt.Errorf("%d resources failed to update", failed)
+		}
+		return nil
+	}
+}
+
+func CleanupCommand() func() error {
+	return func() error {
+		cmd := flag.NewFlagSet("cleanup", flag.ExitOnError)
+		var dryRun bool
+		cmd.BoolVar(&dryRun, "dry-run", false, "List the update branches that would be deleted without deleting them")
+		cmd.Parse(os.Args[2:])
+		logger.Println("Cleaning up update branches")
+
+		cnf, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
+
+		restore, err := git.Configure(&cnf)
+		defer func() {
+			if err := restore(); err != nil {
+				logger.Println(err)
+			}
+		}()
+		if err != nil {
+			return err
 		}
 
-		if cnf.Themes.Enabled {
-			fmt.Println("Performing theme updates")
-			theme.UpdateThemes(&cnf, dryRun, stats)
+		resources, _ := collectResources(&cnf)
+		return updater.Cleanup(&cnf, resources, dryRun)
+	}
+}
+
+// collectResources gathers the enabled resources along with a report of
+// those that could not be loaded.
+func collectResources(cnf *config.Config) ([]interfaces.Resource, updater.Report) {
+	resources := []interfaces.Resource{}
+	report := updater.Report{}
+
+	if cnf.Plugins.Enabled {
+		logger.Println("Collecting plugin updates")
+		plugins, discovered := plugin.GetPlugins(cnf)
+		resources = append(resources, plugins...)
+		report.Merge(discovered)
+	} else {
+		logger.Println("Plugin updates disabled")
+	}
+
+	if cnf.Themes.Enabled {
+		logger.Println("Collecting theme updates")
+		themes, discovered := theme.GetThemes(cnf)
+		resources = append(resources, themes...)
+		report.Merge(discovered)
+	} else {
+		logger.Println("Theme updates disabled")
+	}
+
+	if cnf.Core.Enabled {
+		logger.Println("Collecting core updates")
+		wordpress, err := core.GetCore(cnf)
+		if err != nil {
+			logger.Printf("[core] %s, skipping\n", err)
+			report.Add(constants.CoreResource, "wordpress", err, "")
 		} else {
-			fmt.Println("Theme updates disabled")
+			resources = append(resources, wordpress)
 		}
+	} else {
+		logger.Println("Core updates disabled")
 	}
+
+	return resources, report
 }